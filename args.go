@@ -0,0 +1,43 @@
+package main
+
+import "strings"
+
+// parseArgs splits command arguments into positional arguments and
+// "--name value" flags. Flags listed in boolFlags take no value.
+func parseArgs(args []string, boolFlags ...string) ([]string, map[string]string) {
+	var positional []string
+	flags := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || arg == "--" {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := cleanInput(strings.TrimPrefix(arg, "--"))
+
+		//allow --name=value as well as --name value
+		if before, after, ok := strings.Cut(name, "="); ok {
+			flags[before] = after
+			continue
+		}
+
+		isBool := false
+		for _, b := range boolFlags {
+			if b == name {
+				isBool = true
+			}
+		}
+
+		if isBool || i+1 >= len(args) || strings.HasPrefix(args[i+1], "--") {
+			flags[name] = "true"
+			continue
+		}
+
+		flags[name] = args[i+1]
+		i++
+	}
+
+	return positional, flags
+}
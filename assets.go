@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultAssetDir follows the XDG base directory spec for cached files
+func defaultAssetDir() string {
+	cacheHome, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "pokedexcli", "assets")
+	}
+	return filepath.Join(cacheHome, "pokedexcli", "assets")
+}
+
+// fetchAsset returns a sprite or cry, downloading it only the first time.
+// Assets never change, so unlike API data they are kept on disk for good.
+func (s *session) fetchAsset(ctx context.Context, url string) ([]byte, error) {
+	if s.assets == nil {
+		return s.client.Download(ctx, url)
+	}
+	if data, ok := s.assets.Get(url); ok {
+		return data, nil
+	}
+
+	fmt.Fprintln(s.out, "Downloading:", url) //optional logging
+	data, err := s.client.Download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.assets.Put(url, data); err != nil {
+		fmt.Fprintln(s.out, "could not store asset:", err)
+	}
+	return data, nil
+}
+
+// formatBytes prints a byte count in the largest sensible unit
+func formatBytes(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(n)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+func commandAssets(ctx context.Context, s *session, args ...string) error {
+	if s.assets == nil {
+		return fmt.Errorf("the asset store is not available")
+	}
+
+	positional, flags := parseArgs(args, "all")
+	if len(positional) > 0 && positional[0] == "prune" {
+		files, freed, err := s.assets.Prune(flags["all"] == "true")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "Removed %d file(s), freeing %s.\n", files, formatBytes(freed))
+		return nil
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("usage: assets [prune [--all]]")
+	}
+
+	usage, err := s.assets.Usage()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Assets in %s:\n", s.assetDir)
+	fmt.Fprintf(s.out, " - %d URL(s) stored in %d file(s)\n", usage.URLs, usage.Files)
+	fmt.Fprintf(s.out, " - %s on disk\n", formatBytes(usage.Bytes))
+	return nil
+}
+
+func commandCry(ctx context.Context, s *session, args ...string) error {
+	names, flags := parseArgs(args, "legacy")
+	if len(names) == 0 {
+		return fmt.Errorf("usage: cry <pokemon|#id> [--legacy]")
+	}
+
+	pokemonData, _, err := s.lookupPokemon(ctx, names...)
+	if err != nil {
+		return err
+	}
+
+	url := pokemonData.Cries.Latest
+	if flags["legacy"] == "true" {
+		url = pokemonData.Cries.Legacy
+	}
+	if url == "" {
+		return fmt.Errorf("%s has no such cry", pokemonData.Name)
+	}
+
+	if _, err := s.fetchAsset(ctx, url); err != nil {
+		return err
+	}
+	if s.assets == nil {
+		fmt.Fprintf(s.out, "%s's cry: %s\n", pokemonData.Name, url)
+		return nil
+	}
+	path, _ := s.assets.Path(url)
+	fmt.Fprintf(s.out, "%s's cry is saved at %s\n", pokemonData.Name, path)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// battlePower sums a pokemon's stats with a bit of luck thrown in
+func battlePower(r *rand.Rand, stats map[string]int) float64 {
+	total := 0
+	for _, stat := range stats {
+		total += stat
+	}
+	return float64(total) * (0.8 + r.Float64()*0.4)
+}
+
+// pickWildPokemon chooses an opponent: the named pokemon, or a random one
+// from the last explored area
+func pickWildPokemon(s *session, args []string) (string, error) {
+	if len(args) > 0 {
+		return normalizeName(args...), nil
+	}
+	if len(s.areaLevels) == 0 {
+		return "", fmt.Errorf("explore an area first, or name a pokemon to battle")
+	}
+
+	var names []string
+	for name := range s.areaLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names[s.rand.Intn(len(names))], nil
+}
+
+func commandBattle(ctx context.Context, s *session, args ...string) error {
+	if len(s.storage.Party) == 0 {
+		return fmt.Errorf("you have no pokemon to battle with")
+	}
+	lead := &s.storage.Party[0]
+
+	wildName, err := pickWildPokemon(s, args)
+	if err != nil {
+		return err
+	}
+	wildData, err := resolvePokemon(ctx, s.client, wildName)
+	if err != nil {
+		return err
+	}
+	wild := caughtPokemon{
+		Species: speciesName(*wildData),
+		Level:   s.areaLevels[wildData.Name].roll(s.rand),
+		IVs:     rollIVs(s.rand),
+	}
+
+	//meeting a pokemon in battle counts as seeing it
+	if _, seen := s.pokedex[wild.Species]; !seen {
+		s.pokedex[wild.Species] = pokedexEntry{Species: *wildData, SeenAt: s.clock.Now()}
+	}
+
+	fmt.Fprintf(s.out, "A wild %s (Lv %d) appeared!\n", wild.Species, wild.Level)
+	fmt.Fprintf(s.out, "Go, %s!\n", lead.displayName())
+
+	leadPower := battlePower(s.rand, lead.stats(s.pokedex[lead.Species].Species))
+	wildPower := battlePower(s.rand, wild.stats(*wildData))
+
+	if leadPower >= wildPower {
+		fmt.Fprintf(s.out, "The wild %s fainted!\n", wild.Species)
+
+		gained := lead.gainEVs(effortYield(*wildData))
+		var gains []string
+		for _, stat := range statNames {
+			if gained[stat] > 0 {
+				gains = append(gains, fmt.Sprintf("+%d %s", gained[stat], stat))
+			}
+		}
+		if len(gains) > 0 {
+			fmt.Fprintf(s.out, "%s gained %s EVs.\n", lead.displayName(), strings.Join(gains, ", "))
+		}
+
+		rate, err := s.growthRateOf(ctx, *lead, s.pokedex[lead.Species].Species)
+		if err != nil {
+			return err
+		}
+		for _, line := range s.gainExperience(lead, experienceYield(wildData.BaseExperience, wild.Level), rate) {
+			fmt.Fprintln(s.out, line)
+		}
+	} else {
+		fmt.Fprintf(s.out, "%s fainted! You hurry away from the wild %s.\n", lead.displayName(), wild.Species)
+	}
+
+	return s.save()
+}
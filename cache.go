@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// defaultCacheDir keeps API responses between sessions, next to the assets
+func defaultCacheDir() string {
+	return filepath.Join(filepath.Dir(defaultAssetDir()), "api")
+}
+
+// describeTier summarises one tier of the cache
+func describeTier(t pokecache.TierStats) string {
+	s := fmt.Sprintf("%d entries, %s", t.Entries, formatBytes(t.RawBytes))
+	if t.Compressed > 0 {
+		s += fmt.Sprintf(" stored in %s (%d compressed)", formatBytes(t.StoredBytes), t.Compressed)
+	}
+	return s
+}
+
+func commandCache(ctx context.Context, s *session, args ...string) error {
+	usage := fmt.Errorf("usage: cache stats | cache export <file> | cache import <file>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch cleanInput(args[0]) {
+	case "stats":
+		stats := s.cache.Stats()
+		fmt.Fprintf(s.out, "Memory: %s\n", describeTier(stats.Memory))
+		if dir := s.settings["cache_dir"].value; dir != "off" {
+			fmt.Fprintf(s.out, "Disk:   %s in %s\n", describeTier(stats.Disk), dir)
+		} else {
+			fmt.Fprintln(s.out, "Disk:   off")
+		}
+		return nil
+
+	case "export":
+		if len(args) != 2 {
+			return usage
+		}
+		return exportCache(s, args[1])
+
+	case "import":
+		if len(args) != 2 {
+			return usage
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		restored, err := s.cache.Restore(f)
+		if err != nil {
+			return fmt.Errorf("could not import %s: %w", args[1], err)
+		}
+		fmt.Fprintf(s.out, "Imported %d entries from %s.\n", restored, args[1])
+		return nil
+	}
+
+	return usage
+}
+
+// exportCache writes a cache snapshot to path, leaving no half-written file
+// behind on failure
+func exportCache(s *session, path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	written, err := s.cache.Snapshot(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not export the cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Exported %d entries to %s.\n", written, path)
+	return nil
+}
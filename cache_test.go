@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+func TestCommandCacheExportImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workshop.cache")
+	source := pokecache.NewCache(time.Minute)
+	source.Add("https://pokeapi.co/api/v2/pokemon/25/", []byte(`{"name": "pikachu"}`))
+	if err := commandCache(context.Background(), newSession(&config{}, source, nil, io.Discard), "export", path); err != nil {
+		t.Fatal(err)
+	}
+
+	target := pokecache.NewCache(time.Minute)
+	s := newSession(&config{}, target, nil, io.Discard)
+	if err := commandCache(context.Background(), s, "import", path); err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := target.Get("https://pokeapi.co/api/v2/pokemon/25/"); !ok || string(val) != `{"name": "pikachu"}` {
+		t.Errorf("expected the imported entry, got %q", val)
+	}
+
+	if err := commandCache(context.Background(), s, "import", path+".missing"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
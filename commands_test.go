@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/assets"
+	"github.com/placki-w/pokedexcli/internal/clocktest"
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+	"github.com/placki-w/pokedexcli/internal/pokeapitest"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// testSession starts a pokedex with nothing caught, talking to a fake API
+// serving the fixtures in testdata/pokeapi. Its output goes to out and
+// chance is seeded, so the same commands always print the same thing.
+func testSession(t *testing.T, in io.Reader) (s *session, out *bytes.Buffer, api *pokeapitest.Server) {
+	t.Helper()
+	api = pokeapitest.NewServer(t, filepath.Join("testdata", "pokeapi"))
+	dir := t.TempDir()
+
+	c := &config{
+		savePath:   filepath.Join(dir, "pokedex.json"),
+		configPath: filepath.Join(dir, "config"),
+		assetDir:   filepath.Join(dir, "assets"),
+		throws:     make(map[string]int),
+		language:   "en",
+		color:      "truecolor",
+		clock:      clocktest.NewFake(time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)),
+	}
+	var err error
+	if c.settings, err = resolveSettings(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	cache := pokecache.NewCache(time.Minute, pokecache.WithClock(c.clock))
+	c.client = pokeapi.NewClient(cache,
+		pokeapi.WithBaseURL(api.URL),
+		pokeapi.WithRetries(0),
+		pokeapi.WithMirror(pokeapi.NewMirror(filepath.Join(dir, "api")), false),
+		pokeapi.WithClock(c.clock),
+	)
+	c.nextUrl = c.client.URL("location-area/")
+
+	out = &bytes.Buffer{}
+	s = newSession(c, cache, in, out)
+	//the goldens are what the commands say, not which requests they made
+	c.client.Log = nil
+	s.rand = newRand(1)
+	if s.pokedex, s.storage, err = loadPokedex(c.savePath); err != nil {
+		t.Fatal(err)
+	}
+	if s.assets, err = assets.Open(c.assetDir); err != nil {
+		t.Fatal(err)
+	}
+	return s, out, api
+}
+
+func TestCommands(t *testing.T) {
+	catch := []string{"catch", "pikachu", "--ball", "master-ball", "--nickname", "sparky"}
+
+	cases := []struct {
+		name    string
+		before  [][]string
+		fail    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "help", args: []string{"help"}, want: []string{"Welcome to the Pokedex!", "catch: Attempt to catch a pokemon"}},
+
+		{name: "map", args: []string{"map"}, want: []string{"viridian-forest-area\npewter-city-area\n"}},
+		{name: "map next page", before: [][]string{{"map"}}, args: []string{"map"}, want: []string{"route-1-area"}},
+		{name: "map last page", before: [][]string{{"map"}, {"map"}}, args: []string{"map"}, want: []string{"you're on the last page"}},
+		{name: "map api down", fail: "/api/v2/location-area/", args: []string{"map"}, wantErr: "status code: 503"},
+		{name: "mapb first page", args: []string{"mapb"}, want: []string{"you're on the first page"}},
+		{name: "mapb", before: [][]string{{"map"}, {"map"}}, args: []string{"mapb"}, want: []string{"viridian-forest-area"}},
+
+		{name: "explore", args: []string{"explore", "viridian-forest-area"}, want: []string{"Exploring Viridian Forest...", "Found Pokemon:\n - caterpie\n - pikachu\n"}},
+		{name: "explore empty area", args: []string{"explore", "pewter-city-area"}, want: []string{"No Pokemon found in this area."}},
+		{name: "explore unknown", args: []string{"explore", "cerulean-cave"}, wantErr: "unknown location area: cerulean-cave"},
+		{name: "explore missing area", args: []string{"explore"}, wantErr: "missing location area name or id"},
+
+		{name: "catch", args: catch, want: []string{"Throwing a master-ball at pikachu...", "pikachu was caught! #1 sparky (pikachu)", "sent to your party"}},
+		{name: "catch with a lead", before: [][]string{catch}, args: catch, want: []string{"sparky gained", "experience."}},
+		{name: "catch form-only species", args: []string{"catch", "deoxys", "--ball", "master-ball"}, want: []string{"Throwing a master-ball at deoxys...", "deoxys was caught! #1 deoxys"}},
+		{name: "catch unknown", args: []string{"catch", "missingno", "--ball", "master-ball"}, wantErr: "unknown pokemon: missingno"},
+		{name: "catch unknown ball", args: []string{"catch", "pikachu", "--ball", "net-ball"}, wantErr: "unknown ball: net-ball"},
+		{name: "catch missing pokemon", args: []string{"catch"}, wantErr: "missing pokemon name or id"},
+		{name: "catch api down", fail: "/api/v2/pokemon/pikachu/", args: catch, wantErr: "status code: 503"},
+
+		{name: "inspect not caught", args: []string{"inspect", "pikachu"}, want: []string{"you have not caught that pokemon"}},
+		{name: "inspect species", before: [][]string{catch}, args: []string{"inspect", "25"}, want: []string{"Name: pikachu", "Yours:\n\t- #1 sparky (pikachu)"}},
+		{name: "inspect own", before: [][]string{catch}, args: []string{"inspect", "#1"}, want: []string{"Number: #1", "Nickname: sparky", "Nature: hardy", "Moves: thunder-shock, growl"}},
+		{name: "inspect sprite", before: [][]string{catch}, args: []string{"inspect", "pikachu", "--sprite"}, want: []string{"Name: pikachu", "\x1b["}},
+		{name: "inspect caught in an area", before: [][]string{{"explore", "viridian-forest-area"}, catch}, args: []string{"inspect", "#1"}, want: []string{"Caught: 2025-03-01 12:00 at viridian-forest-area"}},
+		{name: "inspect missing own", args: []string{"inspect", "#9"}, wantErr: "you don't have a pokemon #9"},
+		{name: "inspect missing pokemon", args: []string{"inspect"}, wantErr: "missing pokemon name or id"},
+
+		{name: "pokedex empty", args: []string{"pokedex"}, want: []string{"No pokemon in your pokedex match that.", "Completion: 0% national"}},
+		{name: "pokedex", before: [][]string{catch}, args: []string{"pokedex", "--type", "electric"}, want: []string{"25  pikachu  electric  320  2025-03-01 12:00", "Completion: 1/151 Kanto"}},
+		{name: "pokedex seen", before: [][]string{catch, {"battle", "caterpie"}}, args: []string{"pokedex", "--seen"}, want: []string{"caterpie  bug       195  seen"}},
+		{name: "pokedex form-only species", before: [][]string{{"catch", "deoxys", "--ball", "master-ball"}}, args: []string{"pokedex"}, want: []string{"386  deoxys  psychic  600  2025-03-01 12:00"}},
+		{name: "inspect form-only species", before: [][]string{{"catch", "deoxys", "--ball", "master-ball"}}, args: []string{"inspect", "386"}, want: []string{"Name: deoxys-normal", "Yours:\n\t- #1 deoxys"}},
+		{name: "pokedex bad sort", args: []string{"pokedex", "--sort", "height"}, wantErr: "unknown sort key: height"},
+		{name: "pokedex bad generation", args: []string{"pokedex", "--generation", "42"}, wantErr: "unknown generation: 42"},
+
+		{name: "sprite", args: []string{"sprite", "pikachu", "--width", "2"}, want: []string{"Downloading:", "\x1b[38;2;"}},
+		{name: "sprite 256 colours", args: []string{"sprite", "pikachu", "--256"}, want: []string{"\x1b[38;5;"}},
+		{name: "sprite missing", args: []string{"sprite", "pikachu", "--shiny"}, wantErr: "not found"},
+		{name: "sprite unknown version", args: []string{"sprite", "pikachu", "--version", "gen-x"}, wantErr: "unknown sprite version gen-x"},
+		{name: "sprite bad width", args: []string{"sprite", "pikachu", "--width", "wide"}, wantErr: "expected a width in columns, got wide"},
+		{name: "sprite usage", args: []string{"sprite"}, wantErr: "usage: sprite"},
+
+		{name: "cry", args: []string{"cry", "pikachu"}, want: []string{"pikachu's cry is saved at"}},
+		{name: "cry legacy missing", args: []string{"cry", "pikachu", "--legacy"}, wantErr: "pikachu has no such cry"},
+		{name: "cry usage", args: []string{"cry"}, wantErr: "usage: cry"},
+
+		{name: "assets", before: [][]string{{"cry", "pikachu"}}, args: []string{"assets"}, want: []string{" - 1 URL(s) stored in 1 file(s)"}},
+		{name: "assets prune", before: [][]string{{"cry", "pikachu"}}, args: []string{"assets", "prune", "--all"}, want: []string{"Removed"}},
+
+		{name: "cache stats", before: [][]string{{"map"}}, args: []string{"cache", "stats"}, want: []string{"Memory: 1 entries"}},
+		{name: "cache usage", args: []string{"cache"}, wantErr: "usage: cache stats"},
+		{name: "cache import missing", args: []string{"cache", "import", "nowhere.cache"}, wantErr: "no such file"},
+
+		{name: "sync", args: []string{"sync", "nature", "--limit", "1"}, want: []string{"nature: 1/1", "Done."}},
+		{name: "sync bad limit", args: []string{"sync", "--limit", "some"}, wantErr: "expected a number of resources per endpoint, got some"},
+		{name: "sync unknown endpoint", args: []string{"sync", "berry"}, wantErr: "syncing berry"},
+
+		{name: "config get", args: []string{"config", "get", "language"}, want: []string{"en\n"}},
+		{name: "config list", args: []string{"config", "list"}, want: []string{`shiny_odds            "4096"`}},
+		{name: "config unknown", args: []string{"config", "get", "colour"}, wantErr: "unknown setting colour"},
+		{name: "config usage", args: []string{"config"}, wantErr: "usage: config list"},
+
+		{name: "battle", before: [][]string{catch}, args: []string{"battle", "caterpie"}, want: []string{"A wild caterpie (Lv 5) appeared!", "Go, sparky!"}},
+		{name: "battle empty area", before: [][]string{catch, {"explore", "pewter-city-area"}}, args: []string{"battle"}, wantErr: "explore an area first"},
+		{name: "battle without pokemon", args: []string{"battle", "caterpie"}, wantErr: "you have no pokemon to battle with"},
+
+		{name: "party empty", args: []string{"party"}, want: []string{"Your party is empty, go catch some pokemon!"}},
+		{name: "party", before: [][]string{catch, catch}, args: []string{"party"}, want: []string{"Party:\n 1. #1 sparky (pikachu)", " 2. #2 sparky (pikachu)"}},
+		{name: "box empty", args: []string{"box"}, want: []string{"Box 1 is empty."}},
+		{name: "box", before: [][]string{catch, catch, {"deposit", "#1"}}, args: []string{"box", "1"}, want: []string{"Box 1 (1/30):\n - #1 sparky (pikachu)"}},
+		{name: "box bad number", args: []string{"box", "first"}, wantErr: "expected a box number, got first"},
+		{name: "deposit", before: [][]string{catch, catch}, args: []string{"deposit", "#1", "1"}, want: []string{"#1 was moved to box 1."}},
+		{name: "deposit missing", args: []string{"deposit", "#1"}, wantErr: "you don't have a pokemon #1"},
+		{name: "deposit usage", args: []string{"deposit"}, wantErr: "usage: deposit"},
+		{name: "withdraw", before: [][]string{catch, catch, {"deposit", "#1"}}, args: []string{"withdraw", "#1"}, want: []string{"#1 joined your party."}},
+		{name: "withdraw bad id", args: []string{"withdraw", "one"}, wantErr: "expected a pokemon number like #3, got one"},
+		{name: "release", before: [][]string{catch}, args: []string{"release", "#1"}, want: []string{"sparky was released. Bye!"}},
+		{name: "release usage", args: []string{"release"}, wantErr: "usage: release"},
+		{name: "swap", before: [][]string{catch, catch}, args: []string{"swap", "#1", "#2"}, want: []string{"#1 and #2 swapped places."}},
+		{name: "swap missing", before: [][]string{catch}, args: []string{"swap", "#1", "#2"}, wantErr: "you don't have a pokemon #2"},
+		{name: "swap usage", args: []string{"swap", "#1"}, wantErr: "usage: swap"},
+
+		{name: "exit", before: [][]string{catch}, args: []string{"exit"}, want: []string{"Closing the Pokedex... Goodbye!"}, wantErr: "exit"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, out, api := testSession(t, nil)
+			run := func(args []string) (string, error) {
+				cmd, ok := s.commands[args[0]]
+				if !ok {
+					t.Fatalf("unknown command %s", args[0])
+				}
+				out.Reset()
+				err := cmd.callback(context.Background(), s, args[1:]...)
+				return out.String(), err
+			}
+
+			for _, args := range c.before {
+				if output, err := run(args); err != nil {
+					t.Fatalf("%v: %v\n%s", args, err, output)
+				}
+			}
+			if c.fail != "" {
+				api.Fail(c.fail, http.StatusServiceUnavailable)
+			}
+
+			output, err := run(c.args)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v\n%s", err, output)
+			}
+			if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in the output:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// TestSessionGolden types whole games into the prompt and compares
+// everything printed with testdata/golden/<name>.golden. After changing
+// what a command prints, run go test -update and review the diff.
+func TestSessionGolden(t *testing.T) {
+	scripts := map[string][]string{
+		"help": {"help"},
+		"explore and catch": {
+			"map",
+			"explore viridian-forest-area",
+			"catch pikachu --ball master-ball --nickname sparky",
+			"catch caterpie --ball master-ball",
+			"party",
+			"inspect #1",
+			"battle",
+			"pokedex --seen",
+		},
+		"pc": {
+			"catch pikachu --ball master-ball",
+			"catch caterpie --ball master-ball",
+			"deposit #2",
+			"box",
+			"withdraw #2",
+			"swap #1 #2",
+			"release #1",
+			"party",
+		},
+		"mistakes": {"", "teleport", "mapb", "catch", "explore cerulean-cave", "config get colour", "exit", "help"},
+	}
+
+	for name, script := range scripts {
+		t.Run(name, func(t *testing.T) {
+			s, out, api := testSession(t, strings.NewReader(strings.Join(script, "\n")+"\n"))
+			s.run(nil)
+
+			//the fake API and the temp dir change every run
+			got := strings.ReplaceAll(out.String(), api.URL, "{api}")
+			got = strings.ReplaceAll(got, filepath.Dir(s.savePath), "{dir}")
+
+			path := filepath.Join("testdata", "golden", strings.ReplaceAll(name, " ", "-")+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+	"github.com/placki-w/pokedexcli/internal/termimg"
+)
+
+// setting is a default that can be changed with a flag, an environment
+// variable or the config file, in that order
+type setting struct {
+	key         string
+	description string
+	def         func() string
+	check       func(string) error
+	//read once at startup, changing it needs a restart
+	restart bool
+}
+
+// env is the environment variable for the setting, e.g. POKEDEXCLI_CACHE_TTL
+func (s setting) env() string {
+	return "POKEDEXCLI_" + strings.ToUpper(s.key)
+}
+
+// flagName is the command line flag for the setting, e.g. -cache-ttl
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+func fixed(value string) func() string {
+	return func() string { return value }
+}
+
+func checkURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("expected a URL like %s, got %s", pokeapi.DefaultBaseURL, value)
+	}
+	return nil
+}
+
+func checkDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("expected a duration like 5m or 1h, got %s", value)
+	}
+	return nil
+}
+
+func checkTimeout(value string) error {
+	if value == "0" {
+		return nil
+	}
+	return checkDuration(value)
+}
+
+func checkCount(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a whole number, 0 or more, got %s", value)
+	}
+	return nil
+}
+
+func checkColor(value string) error {
+	switch value {
+	case "auto", "truecolor", "256":
+		return nil
+	}
+	return fmt.Errorf("expected auto, truecolor or 256, got %s", value)
+}
+
+func checkNotEmpty(value string) error {
+	if value == "" {
+		return fmt.Errorf("expected a value")
+	}
+	return nil
+}
+
+// settings lists everything the config file can hold
+var settings = []setting{
+	{key: "api_root", description: "PokeAPI to use", def: fixed(pokeapi.DefaultBaseURL), check: checkURL, restart: true},
+	{key: "cache_ttl", description: "how long API responses are cached", def: fixed("5m"), check: checkDuration, restart: true},
+	{key: "cache_resource_ttl", description: "how long single resources like a pokemon or a move are cached, they hardly ever change", def: fixed("24h"), check: checkDuration, restart: true},
+	{key: "cache_stale", description: "how long expired API responses are still used while a fresh copy is fetched in the background", def: fixed("1h"), check: checkTimeout, restart: true},
+	{key: "cache_size", description: "most API responses kept in the cache, 0 for no limit", def: fixed("0"), check: checkCount, restart: true},
+	{key: "cache_dir", description: "where API responses are kept between sessions, off to keep them in memory only", def: defaultCacheDir, check: checkNotEmpty, restart: true},
+	{key: "cache_compress_above", description: "API responses of at least this many bytes are compressed in the cache, 0 for never", def: fixed("1024"), check: checkCount, restart: true},
+	{key: "http_timeout", description: "how long a single API request may take, 0 for no limit", def: fixed(pokeapi.DefaultTimeout.String()), check: checkTimeout, restart: true},
+	{key: "http_retries", description: "how many times a failed API request is tried again", def: fixed(strconv.Itoa(pokeapi.DefaultRetries)), check: checkCount, restart: true},
+	{key: "rate_limit", description: "most API requests sent per second, 0 for no limit", def: fixed(strconv.Itoa(pokeapi.DefaultRateLimit)), check: checkCount, restart: true},
+	{key: "save_path", description: "where the pokedex is saved", def: defaultSavePath, check: checkNotEmpty, restart: true},
+	{key: "data_dir", description: "where sync keeps the offline dataset", def: defaultMirrorDir, check: checkNotEmpty, restart: true},
+	{key: "game_version", description: "version group whose learnsets are used, e.g. red-blue, empty for the newest", def: fixed("")},
+	{key: "language", description: "language of location names, e.g. en, de or ja", def: fixed("en"), check: checkNotEmpty},
+	{key: "color", description: "colours used for sprites: auto, truecolor or 256", def: fixed("auto"), check: checkColor},
+	{key: "prefetch", description: "how many requests may fetch the next page, areas and pokemon in the background, 0 for none", def: fixed("4"), check: checkCount},
+	{key: "shiny_odds", description: "one in how many caught pokemon are shiny, 0 for never", def: fixed(strconv.Itoa(defaultShinyOdds)), check: checkCount},
+}
+
+// findSetting looks a setting up by its key
+func findSetting(key string) (setting, bool) {
+	key = strings.ReplaceAll(cleanInput(key), "-", "_")
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// settingValue is the value in use and where it came from
+type settingValue struct {
+	value  string
+	source string
+}
+
+// defaultConfigPath is $XDG_CONFIG_HOME/pokedexcli/config
+func defaultConfigPath() string {
+	configHome, err := os.UserConfigDir()
+	if err != nil {
+		return "pokedexcli.json"
+	}
+	return filepath.Join(configHome, "pokedexcli", "config")
+}
+
+// loadConfigFile reads the JSON config file. A missing file sets nothing.
+func loadConfigFile(path string) (map[string]string, error) {
+	file := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//numbers are fine too when editing the file by hand
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for key, value := range raw {
+		if _, ok := findSetting(key); !ok {
+			return nil, fmt.Errorf("unknown setting %s", key)
+		}
+		file[key] = fmt.Sprint(value)
+	}
+	return file, nil
+}
+
+// saveConfigFile writes the config file, creating its directory if needed
+func saveConfigFile(path string, file map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// resolveSettings picks every setting from the flags, then the environment,
+// then the config file, and falls back to the defaults
+func resolveSettings(flags, file map[string]string) (map[string]settingValue, error) {
+	resolved := make(map[string]settingValue)
+	for _, s := range settings {
+		v := settingValue{value: s.def(), source: "default"}
+		if value, ok := file[s.key]; ok {
+			v = settingValue{value: value, source: "config file"}
+		}
+		if value, ok := os.LookupEnv(s.env()); ok {
+			v = settingValue{value: value, source: "$" + s.env()}
+		}
+		if value, ok := flags[s.key]; ok {
+			v = settingValue{value: value, source: "-" + s.flagName() + " flag"}
+		}
+		if s.check != nil {
+			if err := s.check(v.value); err != nil {
+				return nil, fmt.Errorf("%s from the %s: %w", s.key, v.source, err)
+			}
+		}
+		resolved[s.key] = v
+	}
+	return resolved, nil
+}
+
+// applySettings copies the settings that can change during a session into cfg
+func applySettings(cfg *config) {
+	cfg.shinyOdds, _ = strconv.Atoi(cfg.settings["shiny_odds"].value)
+	cfg.gameVersion = cfg.settings["game_version"].value
+	cfg.language = cfg.settings["language"].value
+	cfg.color = cfg.settings["color"].value
+	cfg.prefetch, _ = strconv.Atoi(cfg.settings["prefetch"].value)
+}
+
+// staleCacheRetention is how long expired responses are kept in case the
+// API can't be reached to revalidate them
+const staleCacheRetention = 24 * time.Hour
+
+// resourceURL matches single API resources such as .../pokemon/25/, but not
+// lists of them
+var resourceURL = regexp.MustCompile(`/api/v2/[^/]+/[^/?]+/?$`)
+
+// cacheOptions turns the cache settings into what pokecache needs
+func cacheOptions(values map[string]settingValue) (time.Duration, []pokecache.Option) {
+	ttl, _ := time.ParseDuration(values["cache_ttl"].value)
+	resourceTTL, _ := time.ParseDuration(values["cache_resource_ttl"].value)
+	size, _ := strconv.Atoi(values["cache_size"].value)
+	stale, _ := time.ParseDuration(values["cache_stale"].value)
+	compressAbove, _ := strconv.Atoi(values["cache_compress_above"].value)
+	opts := []pokecache.Option{
+		pokecache.WithMaxEntries(size),
+		pokecache.WithStaleFor(max(stale, staleCacheRetention)),
+		pokecache.WithReapInterval(ttl),
+		pokecache.WithTTLRules(pokecache.TTLRule{Pattern: resourceURL, TTL: resourceTTL}),
+		pokecache.WithCompression(compressAbove),
+	}
+	if dir := values["cache_dir"].value; dir != "off" {
+		opts = append(opts, pokecache.WithDiskDir(dir))
+	}
+	return ttl, opts
+}
+
+// clientOptions turns the HTTP settings into what the pokeapi client needs
+func clientOptions(values map[string]settingValue, clk clock.Clock) []pokeapi.Option {
+	timeout, _ := time.ParseDuration(values["http_timeout"].value)
+	retries, _ := strconv.Atoi(values["http_retries"].value)
+	rate, _ := strconv.Atoi(values["rate_limit"].value)
+	stale, _ := time.ParseDuration(values["cache_stale"].value)
+	return []pokeapi.Option{
+		pokeapi.WithBaseURL(values["api_root"].value),
+		pokeapi.WithTimeout(timeout),
+		pokeapi.WithRetries(retries),
+		pokeapi.WithRateLimiter(pokeapi.NewLimiter(clk, float64(rate), max(rate, 1))),
+		pokeapi.WithStaleWhileRevalidate(stale),
+	}
+}
+
+// colorMode turns the color setting into a terminal mode
+func colorMode(color string) termimg.Mode {
+	switch color {
+	case "truecolor":
+		return termimg.TrueColor
+	case "256":
+		return termimg.Color256
+	}
+	return termimg.DetectMode()
+}
+
+func commandConfig(ctx context.Context, s *session, args ...string) error {
+	usage := fmt.Errorf("usage: config list | config get <key> | config set <key> <value> | config unset <key>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch cleanInput(args[0]) {
+	case "list":
+		w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+		keys := make([]string, 0, len(settings))
+		for _, opt := range settings {
+			keys = append(keys, opt.key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := s.settings[key]
+			fmt.Fprintf(w, "%s\t%q\t(%s)\n", key, v.value, v.source)
+		}
+		return w.Flush()
+
+	case "get":
+		if len(args) != 2 {
+			return usage
+		}
+		opt, ok := findSetting(args[1])
+		if !ok {
+			return fmt.Errorf("unknown setting %s", args[1])
+		}
+		fmt.Fprintln(s.out, s.settings[opt.key].value)
+		return nil
+
+	case "set", "unset":
+		unset := cleanInput(args[0]) == "unset"
+		if (unset && len(args) != 2) || (!unset && len(args) < 3) {
+			return usage
+		}
+		opt, ok := findSetting(args[1])
+		if !ok {
+			return fmt.Errorf("unknown setting %s", args[1])
+		}
+		value := strings.Join(args[2:], " ")
+		if !unset && opt.check != nil {
+			if err := opt.check(value); err != nil {
+				return err
+			}
+		}
+
+		file, err := loadConfigFile(s.configPath)
+		if err != nil {
+			return fmt.Errorf("could not read config file %s: %w", s.configPath, err)
+		}
+		if unset {
+			delete(file, opt.key)
+			value = opt.def()
+		} else {
+			file[opt.key] = value
+		}
+		if err := saveConfigFile(s.configPath, file); err != nil {
+			return fmt.Errorf("could not save config file %s: %w", s.configPath, err)
+		}
+
+		current := s.settings[opt.key]
+		switch {
+		case current.source != "default" && current.source != "config file":
+			fmt.Fprintf(s.out, "Saved %s, but the %s still takes precedence.\n", opt.key, current.source)
+		case opt.restart:
+			fmt.Fprintf(s.out, "Saved %s, restart the pokedex for it to take effect.\n", opt.key)
+		default:
+			source := "config file"
+			if unset {
+				source = "default"
+			}
+			s.settings[opt.key] = settingValue{value: value, source: source}
+			applySettings(s.config)
+			fmt.Fprintf(s.out, "%s is now %q.\n", opt.key, value)
+		}
+		return nil
+	}
+
+	return usage
+}
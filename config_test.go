@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(`{"api_root": "http://file.lan/api/v2/", "shiny_odds": 100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file["shiny_odds"] != "100" {
+		t.Errorf("expected numbers in the config file to be read, got %q", file["shiny_odds"])
+	}
+
+	t.Setenv("POKEDEXCLI_API_ROOT", "")
+	os.Unsetenv("POKEDEXCLI_API_ROOT")
+	resolved, err := resolveSettings(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := resolved["api_root"]; v.value != "https://pokeapi.co/api/v2/" || v.source != "default" {
+		t.Errorf("expected the public API by default, got %+v", v)
+	}
+
+	resolved, _ = resolveSettings(nil, file)
+	if v := resolved["api_root"]; v.value != "http://file.lan/api/v2/" {
+		t.Errorf("expected the config file root, got %+v", v)
+	}
+
+	t.Setenv("POKEDEXCLI_API_ROOT", "http://env.lan/api/v2/")
+	resolved, _ = resolveSettings(nil, file)
+	if v := resolved["api_root"]; v.value != "http://env.lan/api/v2/" {
+		t.Errorf("expected the environment to beat the config file, got %+v", v)
+	}
+
+	resolved, _ = resolveSettings(map[string]string{"api_root": "http://flag.lan/api/v2/"}, file)
+	if v := resolved["api_root"]; v.value != "http://flag.lan/api/v2/" || v.source != "-api-root flag" {
+		t.Errorf("expected the flag to beat everything, got %+v", v)
+	}
+}
+
+func TestResolveSettingsChecksValues(t *testing.T) {
+	_, err := resolveSettings(nil, map[string]string{"cache_ttl": "soon"})
+	if err == nil || !strings.Contains(err.Error(), "config file") {
+		t.Errorf("expected a bad cache_ttl in the config file to be reported, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(`{"colour_scheme": "dark"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(path); err == nil {
+		t.Errorf("expected an unknown key to be an error")
+	}
+}
+
+func TestCommandConfigSet(t *testing.T) {
+	c := &config{configPath: filepath.Join(t.TempDir(), "pokedexcli", "config")}
+	var err error
+	c.settings, err = resolveSettings(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSession(c, nil, nil, io.Discard)
+
+	if err := commandConfig(context.Background(), s, "set", "shiny-odds", "16"); err != nil {
+		t.Fatal(err)
+	}
+	if c.shinyOdds != 16 {
+		t.Errorf("expected shiny odds to change right away, got %d", c.shinyOdds)
+	}
+	if err := commandConfig(context.Background(), s, "set", "color", "sepia"); err == nil {
+		t.Errorf("expected an invalid colour to be refused")
+	}
+
+	file, err := loadConfigFile(c.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file) != 1 || file["shiny_odds"] != "16" {
+		t.Errorf("unexpected config file: %v", file)
+	}
+
+	if err := commandConfig(context.Background(), s, "unset", "shiny_odds"); err != nil {
+		t.Fatal(err)
+	}
+	if c.shinyOdds != defaultShinyOdds {
+		t.Errorf("expected unset to restore the default, got %d", c.shinyOdds)
+	}
+}
+
+func TestResourceURL(t *testing.T) {
+	cases := map[string]bool{
+		"https://pokeapi.co/api/v2/pokemon/25/":                       true,
+		"https://pokeapi.co/api/v2/pokemon-species/pikachu/":          true,
+		"https://pokeapi.co/api/v2/location-area/":                    false,
+		"https://pokeapi.co/api/v2/location-area/?offset=20&limit=20": false,
+		"https://pokeapi.co/api/v2/nature/?limit=100":                 false,
+	}
+	for url, expected := range cases {
+		if resourceURL.MatchString(url) != expected {
+			t.Errorf("%s: expected %v", url, expected)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+const (
+	maxLevel = 100
+	maxMoves = 4
+)
+
+// speciesDetails is the part of /pokemon-species we use. Unlike
+// pokemonDetails it is shared by all forms of a species.
+type speciesDetails struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	GenderRate int    `json:"gender_rate"`
+	GrowthRate struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"growth_rate"`
+	Generation struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"generation"`
+	Varieties []struct {
+		IsDefault bool `json:"is_default"`
+		Pokemon   struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"pokemon"`
+	} `json:"varieties"`
+}
+
+// growthRate is the experience needed to reach each level for one curve
+type growthRate struct {
+	Name   string `json:"name"`
+	Levels []struct {
+		Level      int `json:"level"`
+		Experience int `json:"experience"`
+	} `json:"levels"`
+}
+
+// fetchSpecies gets the species data for a pokemon
+func fetchSpecies(ctx context.Context, client *pokeapi.Client, p pokemonDetails) (*speciesDetails, error) {
+	body, err := client.Get(ctx, p.Species.URL)
+	if err != nil {
+		return nil, err
+	}
+	var species *speciesDetails = &speciesDetails{}
+	if err := json.Unmarshal(body, species); err != nil {
+		return nil, err
+	}
+	return species, nil
+}
+
+// fetchGrowthRate gets a growth curve by name, e.g. "medium-slow"
+func fetchGrowthRate(ctx context.Context, client *pokeapi.Client, name string) (*growthRate, error) {
+	body, err := client.Get(ctx, client.URL("growth-rate/"+name+"/"))
+	if err != nil {
+		return nil, err
+	}
+	var rate *growthRate = &growthRate{}
+	if err := json.Unmarshal(body, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// experienceFor returns the total experience needed to reach level
+func (g growthRate) experienceFor(level int) int {
+	for _, l := range g.Levels {
+		if l.Level == level {
+			return l.Experience
+		}
+	}
+	return 0
+}
+
+// levelFor returns the level a pokemon with the given experience is at
+func (g growthRate) levelFor(experience int) int {
+	level := 1
+	for _, l := range g.Levels {
+		if experience >= l.Experience && l.Level > level {
+			level = l.Level
+		}
+	}
+	return level
+}
+
+// experienceYield is the experience for defeating or catching a pokemon,
+// using the flat formula from generation VI without trainer bonuses
+func experienceYield(baseExperience, level int) int {
+	return max(1, baseExperience*level/5)
+}
+
+// levelUpMove is a move a species learns by levelling up
+type levelUpMove struct {
+	Name  string
+	Level int
+}
+
+// learnset returns the moves a species learns by level up, in the order it
+// learns them. Without a version group the newest listed learn data is used.
+func learnset(p pokemonDetails, versionGroup string) []levelUpMove {
+	var moves []levelUpMove
+	for _, move := range p.Moves {
+		level := -1
+		for _, details := range move.VersionGroupDetails {
+			if details.MoveLearnMethod.Name != "level-up" {
+				continue
+			}
+			if versionGroup == "" || details.VersionGroup.Name == versionGroup {
+				level = details.LevelLearnedAt
+			}
+		}
+		if level >= 0 {
+			moves = append(moves, levelUpMove{Name: move.Move.Name, Level: level})
+		}
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].Level < moves[j].Level })
+	return moves
+}
+
+// learnMove teaches a move, forgetting the oldest one when four are known.
+// It returns the forgotten move, if any.
+func (c *caughtPokemon) learnMove(move string) (string, bool) {
+	if slices.Contains(c.Moves, move) {
+		return "", false
+	}
+	forgotten := ""
+	if len(c.Moves) >= maxMoves {
+		forgotten = c.Moves[0]
+		c.Moves = c.Moves[1:]
+	}
+	c.Moves = append(c.Moves, move)
+	return forgotten, true
+}
+
+// initMoves gives a freshly caught pokemon the latest moves it would know
+// at its level
+func (c *caughtPokemon) initMoves(p pokemonDetails, versionGroup string) {
+	for _, move := range learnset(p, versionGroup) {
+		if move.Level <= c.Level {
+			c.learnMove(move.Name)
+		}
+	}
+}
+
+// growthRateOf looks up the growth rate of a pokemon of the given species
+func (s *session) growthRateOf(ctx context.Context, c caughtPokemon, species pokemonDetails) (*growthRate, error) {
+	name := c.GrowthRate
+	if name == "" {
+		details, err := fetchSpecies(ctx, s.client, species)
+		if err != nil {
+			return nil, err
+		}
+		name = details.GrowthRate.Name
+	}
+	return fetchGrowthRate(ctx, s.client, name)
+}
+
+// gainExperience adds experience along the growth rate from growthRateOf,
+// levelling up and learning moves along the way, and returns what happened
+func (s *session) gainExperience(c *caughtPokemon, amount int, rate *growthRate) []string {
+	c.GrowthRate = rate.Name
+
+	//pokemon from before experience existed start at the bottom of their level
+	if c.Experience < rate.experienceFor(c.Level) {
+		c.Experience = rate.experienceFor(c.Level)
+	}
+	if c.Level >= maxLevel {
+		return nil
+	}
+
+	c.Experience += amount
+	events := []string{fmt.Sprintf("%s gained %d experience.", c.displayName(), amount)}
+
+	moves := learnset(s.pokedex[c.Species].Species, s.gameVersion)
+	newLevel := min(rate.levelFor(c.Experience), maxLevel)
+	for c.Level < newLevel {
+		c.Level++
+		events = append(events, fmt.Sprintf("%s grew to level %d!", c.displayName(), c.Level))
+
+		for _, move := range moves {
+			if move.Level != c.Level {
+				continue
+			}
+			forgotten, learned := c.learnMove(move.Name)
+			switch {
+			case forgotten != "":
+				events = append(events, fmt.Sprintf("%s forgot %s and learned %s!", c.displayName(), forgotten, move.Name))
+			case learned:
+				events = append(events, fmt.Sprintf("%s learned %s!", c.displayName(), move.Name))
+			}
+		}
+	}
+
+	return events
+}
+
+// describeProgress shows experience towards the next level
+func (s *session) describeProgress(ctx context.Context, c caughtPokemon) string {
+	if c.GrowthRate == "" || c.Level >= maxLevel {
+		return fmt.Sprintf("%d", c.Level)
+	}
+	rate, err := fetchGrowthRate(ctx, s.client, c.GrowthRate)
+	if err != nil {
+		return fmt.Sprintf("%d", c.Level)
+	}
+	return fmt.Sprintf("%d (%d XP, %d to next level)", c.Level, c.Experience, rate.experienceFor(c.Level+1)-c.Experience)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGrowthRateLevels(t *testing.T) {
+	var rate growthRate
+	raw := `{"name": "medium", "levels": [
+		{"level": 1, "experience": 0},
+		{"level": 2, "experience": 8},
+		{"level": 3, "experience": 27},
+		{"level": 4, "experience": 64}
+	]}`
+	if err := json.Unmarshal([]byte(raw), &rate); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		experience int
+		expected   int
+	}{
+		{experience: 0, expected: 1},
+		{experience: 7, expected: 1},
+		{experience: 8, expected: 2},
+		{experience: 63, expected: 3},
+		{experience: 1000, expected: 4},
+	}
+	for _, c := range cases {
+		if actual := rate.levelFor(c.experience); actual != c.expected {
+			t.Errorf("%d XP: got level %d, expected %d", c.experience, actual, c.expected)
+		}
+	}
+
+	if actual := rate.experienceFor(3); actual != 27 {
+		t.Errorf("expected 27 XP for level 3, got %d", actual)
+	}
+}
+
+func TestLearnset(t *testing.T) {
+	p := testPokemon(t, `{"moves": [
+		{"move": {"name": "thunderbolt"}, "version_group_details": [
+			{"level_learned_at": 0, "move_learn_method": {"name": "machine"}, "version_group": {"name": "red-blue"}}
+		]},
+		{"move": {"name": "thunder-shock"}, "version_group_details": [
+			{"level_learned_at": 1, "move_learn_method": {"name": "level-up"}, "version_group": {"name": "red-blue"}}
+		]},
+		{"move": {"name": "quick-attack"}, "version_group_details": [
+			{"level_learned_at": 16, "move_learn_method": {"name": "level-up"}, "version_group": {"name": "red-blue"}},
+			{"level_learned_at": 6, "move_learn_method": {"name": "level-up"}, "version_group": {"name": "sword-shield"}}
+		]}
+	]}`)
+
+	moves := learnset(p, "")
+	if len(moves) != 2 || moves[0].Name != "thunder-shock" || moves[1].Name != "quick-attack" || moves[1].Level != 6 {
+		t.Errorf("unexpected learnset: %+v", moves)
+	}
+
+	moves = learnset(p, "red-blue")
+	if len(moves) != 2 || moves[1].Level != 16 {
+		t.Errorf("unexpected red-blue learnset: %+v", moves)
+	}
+}
+
+func TestLearnMove(t *testing.T) {
+	p := caughtPokemon{Moves: []string{"a", "b", "c", "d"}}
+
+	forgotten, learned := p.learnMove("e")
+	if !learned || forgotten != "a" {
+		t.Errorf("expected to forget 'a', got '%s'", forgotten)
+	}
+	if _, learned := p.learnMove("e"); learned {
+		t.Errorf("expected a known move not to be learned again")
+	}
+	if len(p.Moves) != maxMoves {
+		t.Errorf("expected %d moves, got %v", maxMoves, p.Moves)
+	}
+}
@@ -0,0 +1,188 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store keeps immutable binary assets such as sprites and cries on disk.
+// Files are named after the hash of their content, so the same image
+// behind two URLs is only stored once, and nothing ever expires.
+type Store struct {
+	dir   string
+	mutex sync.Mutex
+	index map[string]string
+}
+
+// Usage describes how much the store holds
+type Usage struct {
+	URLs  int
+	Files int
+	Bytes int64
+}
+
+const indexFile = "index.json"
+
+// Open opens the store in dir, creating it if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		dir:   dir,
+		index: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.index); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Get returns the stored content for url
+func (s *Store) Get(url string) ([]byte, bool) {
+	p, ok := s.Path(url)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Path returns the file holding the content for url
+func (s *Store) Path(url string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name, ok := s.index[url]
+	if !ok {
+		return "", false
+	}
+	return s.objectPath(name), true
+}
+
+// Put stores the content for url and returns the file it was written to
+func (s *Store) Put(url string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	//keep the extension so files can be opened by other programs
+	name := hex.EncodeToString(sum[:]) + strings.ToLower(path.Ext(url))
+	p := s.objectPath(name)
+
+	//Prune deletes files the index doesn't know about, so the file and its
+	//index entry have to appear together
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := os.Stat(p); err != nil {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return "", err
+		}
+		tmp := p + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp, p); err != nil {
+			return "", err
+		}
+	}
+
+	s.index[url] = name
+	return p, s.writeIndex()
+}
+
+// Usage reports the number of URLs, files and bytes in the store
+func (s *Store) Usage() (Usage, error) {
+	s.mutex.Lock()
+	usage := Usage{URLs: len(s.index)}
+	s.mutex.Unlock()
+
+	err := filepath.WalkDir(filepath.Join(s.dir, "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		return nil
+	})
+	return usage, err
+}
+
+// Prune deletes files no URL points at. With all set it forgets every URL
+// first, emptying the store. It returns the number of files and bytes freed.
+func (s *Store) Prune(all bool) (int, int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if all {
+		s.index = make(map[string]string)
+		if err := s.writeIndex(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, name := range s.index {
+		used[name] = true
+	}
+
+	files := 0
+	var freed int64
+	err := filepath.WalkDir(filepath.Join(s.dir, "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || used[d.Name()] {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		files++
+		freed += info.Size()
+		return nil
+	})
+	return files, freed, err
+}
+
+// objectPath spreads files over subdirectories named after their first
+// two characters, like git does
+func (s *Store) objectPath(name string) string {
+	return filepath.Join(s.dir, "objects", name[:2], name)
+}
+
+// writeIndex saves the URL index, the caller must hold the mutex
+func (s *Store) writeIndex() error {
+	data, err := json.Marshal(s.index)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, indexFile))
+}
@@ -0,0 +1,96 @@
+package assets
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPutGet(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	png := []byte("not really a png")
+	if _, err := store.Put("https://example.com/25.png", png); err != nil {
+		t.Fatal(err)
+	}
+	//the same content under another URL is only stored once
+	if _, err := store.Put("https://example.com/pikachu.png", png); err != nil {
+		t.Fatal(err)
+	}
+
+	//reopening reads the index back
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, ok := store.Get("https://example.com/pikachu.png")
+	if !ok || string(val) != string(png) {
+		t.Errorf("expected to find the stored content")
+	}
+
+	usage, err := store.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.URLs != 2 || usage.Files != 1 || usage.Bytes != int64(len(png)) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put("https://example.com/25.ogg", []byte("cry")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _, err := store.Prune(false)
+	if err != nil || files != 0 {
+		t.Errorf("expected nothing to prune, got %d files, err %v", files, err)
+	}
+
+	files, freed, err := store.Prune(true)
+	if err != nil || files != 1 || freed != 3 {
+		t.Errorf("expected to prune one 3 byte file, got %d files, %d bytes, err %v", files, freed, err)
+	}
+	if _, ok := store.Get("https://example.com/25.ogg"); ok {
+		t.Errorf("expected the store to be empty")
+	}
+}
+
+func TestPutWhilePruning(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			if _, _, err := store.Prune(false); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := range 50 {
+		if _, err := store.Put(fmt.Sprintf("https://example.com/%d.png", i), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	//pruning never takes a file that was put
+	for i := range 50 {
+		if _, ok := store.Get(fmt.Sprintf("https://example.com/%d.png", i)); !ok {
+			t.Errorf("expected %d.png to survive pruning", i)
+		}
+	}
+}
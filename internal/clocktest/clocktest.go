@@ -0,0 +1,121 @@
+// Package clocktest provides a fake clock that only moves when told to
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
+)
+
+// Fake is a clock.Clock whose time only changes with Advance and Set.
+// Tickers and timers fire as the time passes their deadlines.
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending ticker or After channel
+type waiter struct {
+	deadline time.Time
+	period   time.Duration
+	c        chan time.Time
+	stopped  bool
+}
+
+// NewFake starts a fake clock at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	w := &waiter{deadline: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	return &ticker{fake: f, w: w}
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	w := &waiter{deadline: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	f.fire()
+	return w.c
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+	f.fire()
+}
+
+// Set moves the clock to t
+func (f *Fake) Set(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = t
+	f.fire()
+}
+
+// Waiters is the number of tickers and pending After channels, which lets a
+// test wait for a goroutine to start waiting before advancing the clock
+func (f *Fake) Waiters() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.waiters)
+}
+
+// fire delivers everything that is due. Like a real ticker, a slow reader
+// misses ticks rather than getting a backlog of them.
+func (f *Fake) fire() {
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+		if !w.deadline.After(f.now) {
+			select {
+			case w.c <- f.now:
+			default:
+			}
+			if w.period == 0 {
+				continue
+			}
+			for !w.deadline.After(f.now) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+		}
+		pending = append(pending, w)
+	}
+	f.waiters = pending
+}
+
+type ticker struct {
+	fake *Fake
+	w    *waiter
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *ticker) Stop() {
+	t.fake.mutex.Lock()
+	defer t.fake.mutex.Unlock()
+	t.w.stopped = true
+	t.fake.fire()
+}
@@ -0,0 +1,43 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFake(start)
+
+	ticker := clock.NewTicker(time.Minute)
+	after := clock.After(90 * time.Second)
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Errorf("expected no tick after 30s")
+	case <-after:
+		t.Errorf("expected After not to fire after 30s")
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("expected a tick at 1m30s, got %s", tick)
+	}
+	<-after
+
+	//ticks nobody read are dropped, like a real ticker
+	clock.Advance(10 * time.Minute)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Errorf("expected missed ticks to be dropped")
+	default:
+	}
+
+	ticker.Stop()
+	if clock.Waiters() != 0 {
+		t.Errorf("expected nothing left waiting, got %d", clock.Waiters())
+	}
+}
@@ -0,0 +1,300 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// ErrNotFound is returned when the API has no such resource
+var ErrNotFound = errors.New("not found")
+
+// DefaultBaseURL is the public PokeAPI
+const DefaultBaseURL = "https://pokeapi.co/api/v2/"
+
+// Client fetches PokeAPI resources, going to the cache first and then to
+// either the network or, in offline mode, the local mirror
+type Client struct {
+	baseURL    string
+	cache      *pokecache.Cache
+	httpClient *http.Client
+	mirror     *Mirror
+	offline    bool
+	recordDir  string
+	retries    int
+	limiter    *Limiter
+	//sleep waits between retries, tests swap it out
+	sleep func(context.Context, time.Duration) error
+
+	clock                clock.Clock
+	staleWhileRevalidate time.Duration
+	revalidating         map[string]bool
+	revalidatingMutex    sync.Mutex
+	background           sync.WaitGroup
+
+	// Log receives a line for each request when set
+	Log io.Writer
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithMirror serves requests from a local mirror instead of the network
+// when offline is true. The mirror is also the target of Sync.
+func WithMirror(mirror *Mirror, offline bool) Option {
+	return func(c *Client) {
+		c.mirror = mirror
+		c.offline = offline
+	}
+}
+
+// WithBaseURL points the client at another PokeAPI, such as a self-hosted
+// instance or a fixture server. "http://localhost:8000" and
+// "http://localhost:8000/api/v2/" are the same.
+func WithBaseURL(root string) Option {
+	return func(c *Client) {
+		c.baseURL = NormalizeBaseURL(root)
+	}
+}
+
+// WithClock decides when cached entries count as expired and times retries
+// and the default rate limiter. It should be the same clock the cache uses.
+func WithClock(c clock.Clock) Option {
+	return func(client *Client) {
+		client.clock = c
+	}
+}
+
+// NormalizeBaseURL makes sure an API root ends in "/api/v2/"
+func NormalizeBaseURL(root string) string {
+	root = strings.TrimSuffix(root, "/")
+	if !strings.HasSuffix(root, "/api/v2") {
+		root += "/api/v2"
+	}
+	return root + "/"
+}
+
+// NewClient creates a client that caches API responses in cache. By default
+// requests time out after DefaultTimeout, are retried DefaultRetries times
+// and are limited to DefaultRateLimit per second.
+func NewClient(cache *pokecache.Cache, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		cache:      cache,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		retries:    DefaultRetries,
+		clock:      clock.Real,
+
+		revalidating: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	//these wait on whichever clock the options picked
+	if c.limiter == nil {
+		c.limiter = NewLimiter(c.clock, DefaultRateLimit, DefaultRateLimit)
+	}
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		return sleep(ctx, c.clock, d)
+	}
+	return c
+}
+
+// Offline reports whether the client is serving from its mirror only
+func (c *Client) Offline() bool {
+	return c.offline
+}
+
+// Mirror returns the client's local mirror, if it has one
+func (c *Client) Mirror() *Mirror {
+	return c.mirror
+}
+
+// BaseURL is the API root every request goes to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// URL builds an API URL from a path such as "pokemon/25/"
+func (c *Client) URL(path string) string {
+	return c.baseURL + strings.TrimPrefix(path, "/")
+}
+
+// Rewrite moves an API URL onto the client's root. Resources link to each
+// other with absolute URLs of whichever server produced them, so URLs taken
+// from responses are rewritten before they are requested.
+func (c *Client) Rewrite(url string) string {
+	_, rest, ok := strings.Cut(url, "/api/v2/")
+	if !ok {
+		return url
+	}
+	return c.baseURL + rest
+}
+
+// Get returns the body for an API url, using the cache when it can. Expired
+// entries are revalidated with a conditional request, and served as they
+// are when the API can't be reached.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	url = c.Rewrite(url)
+	body, err := c.get(ctx, url)
+	if err == nil {
+		c.record(ctx, url, body)
+	}
+	return body, err
+}
+
+func (c *Client) get(ctx context.Context, url string) ([]byte, error) {
+	entry, found := c.cache.Lookup(url)
+	now := c.clock.Now()
+	if found && !entry.Stale(now) {
+		c.logf(ctx, "Using cached data for: %s\n", url)
+		return entry.Val, nil
+	}
+
+	if c.offline {
+		c.logf(ctx, "Reading offline data for: %s\n", url)
+		body, err := c.mirror.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.Add(url, body)
+		return body, nil
+	}
+
+	//recently expired data is good enough while a fresh copy is fetched
+	if found && now.Before(entry.ExpiresAt.Add(c.staleWhileRevalidate)) {
+		c.logf(ctx, "Using cached data for: %s (revalidating)\n", url)
+		c.revalidateInBackground(ctx, url, entry)
+		return entry.Val, nil
+	}
+
+	if found {
+		c.logf(ctx, "Revalidating cached data for: %s\n", url)
+	} else {
+		c.logf(ctx, "Fetching new data for: %s\n", url)
+	}
+	body, err := c.revalidate(ctx, url, entry)
+	if err != nil && found && !errors.Is(err, ErrNotFound) && ctx.Err() == nil {
+		c.logf(ctx, "Could not reach the API, using stale data for: %s\n", url)
+		return entry.Val, nil
+	}
+	return body, err
+}
+
+// Add caches a body under another URL, for resources reachable by both
+// name and ID
+func (c *Client) Add(url string, body []byte) {
+	c.cache.Add(c.Rewrite(url), body)
+}
+
+// response is what came back from one GET
+type response struct {
+	body        []byte
+	validators  pokecache.Validators
+	notModified bool
+}
+
+// Download makes the HTTP request for url without going through the cache.
+// Network errors, 429 and 5xx responses are retried with backoff until ctx
+// is done.
+func (c *Client) Download(ctx context.Context, url string) ([]byte, error) {
+	res, err := c.fetch(ctx, url, pokecache.Validators{})
+	if err == nil {
+		c.record(ctx, url, res.body)
+	}
+	return res.body, err
+}
+
+// fetch makes a GET, conditional when validators are given, with retries
+func (c *Client) fetch(ctx context.Context, url string, validators pokecache.Validators) (response, error) {
+	if c.offline {
+		return response{}, fmt.Errorf("%s: %w", url, ErrNotInDataset)
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, wait, err := c.try(ctx, url, validators)
+		if wait < 0 || attempt >= c.retries || ctx.Err() != nil {
+			return res, err
+		}
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		c.logf(ctx, "Retrying %s in %s: %v\n", url, wait.Round(time.Millisecond), err)
+		if err := c.sleep(ctx, wait); err != nil {
+			return response{}, err
+		}
+	}
+}
+
+// try makes one request. A negative wait means the result is final,
+// otherwise it can be retried after wait, or after a backoff when 0.
+func (c *Client) try(ctx context.Context, url string, validators pokecache.Validators) (response, time.Duration, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return response{}, -1, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return response{}, -1, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return response{}, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return response{}, 0, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		return response{notModified: true, validators: validators}, -1, nil
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return response{}, -1, ErrNotFound
+	}
+	if res.StatusCode > 299 {
+		err := fmt.Errorf("response failed with status code: %d and\nbody: %s", res.StatusCode, body)
+		if !retryable(res.StatusCode) {
+			return response{}, -1, err
+		}
+		wait, _ := retryAfter(res, c.clock.Now())
+		return response{}, wait, err
+	}
+	return response{
+		body: body,
+		validators: pokecache.Validators{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+		},
+	}, -1, nil
+}
+
+// quietKey marks contexts whose requests shouldn't be logged
+type quietKey struct{}
+
+// Quietly returns a context whose requests are not logged, for work in the
+// background that would otherwise print over the prompt
+func Quietly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietKey{}, true)
+}
+
+func (c *Client) logf(ctx context.Context, format string, args ...any) {
+	if c.Log != nil && ctx.Value(quietKey{}) == nil {
+		fmt.Fprintf(c.Log, format, args...)
+	}
+}
@@ -0,0 +1,545 @@
+package pokeapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
+	"github.com/placki-w/pokedexcli/internal/clocktest"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// fakeAPI serves a tiny PokeAPI with three location areas and counts requests
+func fakeAPI(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		root := server.URL + "/api/v2/"
+		switch r.URL.Path {
+		case "/api/v2/location-area/":
+			json.NewEncoder(w).Encode(map[string]any{
+				"count": 3,
+				"results": []map[string]string{
+					{"name": "canalave-city-area", "url": root + "location-area/1/"},
+					{"name": "eterna-city-area", "url": root + "location-area/2/"},
+					{"name": "pastoria-city-area", "url": root + "location-area/3/"},
+				},
+			})
+		case "/api/v2/location-area/1/", "/api/v2/location-area/2/", "/api/v2/location-area/3/":
+			w.Write([]byte(`{"id": ` + r.URL.Path[len("/api/v2/location-area/"):len(r.URL.Path)-1] + `}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetCaches(t *testing.T) {
+	requests := 0
+	server := fakeAPI(t, &requests)
+	client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL))
+
+	//URLs from the public API are rewritten to the fake one
+	for _, url := range []string{server.URL + "/api/v2/location-area/1/", "https://pokeapi.co/api/v2/location-area/1/"} {
+		body, err := client.Get(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != `{"id": 1}` {
+			t.Errorf("unexpected body %s", body)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the second get to be cached, got %d requests", requests)
+	}
+
+	if _, err := client.Get(context.Background(), client.URL("location-area/99/")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSyncAndOffline(t *testing.T) {
+	requests := 0
+	server := fakeAPI(t, &requests)
+	mirror := NewMirror(t.TempDir())
+
+	online := NewClient(pokecache.NewCache(time.Minute), WithMirror(mirror, false), WithBaseURL(server.URL))
+	if err := online.Sync(context.Background(), "location-area", 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	offline := NewClient(pokecache.NewCache(time.Minute), WithMirror(mirror, true))
+
+	body, err := offline.Get(context.Background(), "https://pokeapi.co/api/v2/location-area/eterna-city-area/")
+	if err != nil {
+		t.Fatalf("expected to find an area by name: %v", err)
+	}
+	if string(body) != `{"id": 2}` {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	//only two of the three areas were synced
+	if _, err := offline.Get(context.Background(), "https://pokeapi.co/api/v2/location-area/3/"); !errors.Is(err, ErrNotInDataset) {
+		t.Errorf("expected ErrNotInDataset, got %v", err)
+	}
+	if _, err := offline.Get(context.Background(), "https://pokeapi.co/api/v2/pokemon/25/"); !errors.Is(err, ErrNotInDataset) {
+		t.Errorf("expected ErrNotInDataset for an endpoint that was never synced, got %v", err)
+	}
+	if _, err := offline.Get(context.Background(), "https://pokeapi.co/api/v2/location-area/2/encounters"); !errors.Is(err, ErrNotInDataset) {
+		t.Errorf("expected ErrNotInDataset for a sub-resource, got %v", err)
+	}
+
+	//lists are paged like the real API
+	body, err = offline.Get(context.Background(), "https://pokeapi.co/api/v2/location-area/?offset=1&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page resourceList
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 3 || len(page.Results) != 1 || page.Results[0].Name != "eterna-city-area" {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if page.Next == nil || *page.Next != "https://pokeapi.co/api/v2/location-area/?offset=2&limit=1" {
+		t.Errorf("unexpected next page %v", page.Next)
+	}
+	if page.Previous == nil || *page.Previous != "https://pokeapi.co/api/v2/location-area/?offset=0&limit=1" {
+		t.Errorf("unexpected previous page %v", page.Previous)
+	}
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "https://pokeapi.co/api/v2/", expected: "https://pokeapi.co/api/v2/"},
+		{input: "https://pokeapi.co/api/v2", expected: "https://pokeapi.co/api/v2/"},
+		{input: "http://localhost:8000", expected: "http://localhost:8000/api/v2/"},
+		{input: "http://mirror.lan/pokeapi/", expected: "http://mirror.lan/pokeapi/api/v2/"},
+	}
+	for _, c := range cases {
+		if actual := NormalizeBaseURL(c.input); actual != c.expected {
+			t.Errorf("%s: got %s, expected %s", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"id": 1}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL))
+	var waits []time.Duration
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	body, err := client.Download(context.Background(), client.URL("pokemon/1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"id": 1}` || requests != 3 {
+		t.Errorf("expected a body after 3 requests, got %s after %d", body, requests)
+	}
+	if len(waits) != 2 || waits[0] != 7*time.Second {
+		t.Errorf("expected to honour Retry-After first, waited %v", waits)
+	}
+	if waits[1] < baseBackoff || waits[1] > 2*baseBackoff {
+		t.Errorf("expected a backoff with jitter, waited %v", waits[1])
+	}
+}
+
+func TestDownloadRetryAfterDate(t *testing.T) {
+	cache, clock := fakeCache()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", clock.Now().Add(9*time.Second).Format(http.TimeFormat))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	//the fake clock is years behind the real one, so the date is counted on it
+	client := NewClient(cache, WithClock(clock), WithBaseURL(server.URL))
+	var waits []time.Duration
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	if _, err := client.Download(context.Background(), client.URL("pokemon/1/")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(waits, []time.Duration{9 * time.Second}) {
+		t.Errorf("expected to wait until the Retry-After date, waited %v", waits)
+	}
+}
+
+func TestDownloadGivesUp(t *testing.T) {
+	cases := []struct {
+		status   int
+		requests int
+	}{
+		{status: http.StatusServiceUnavailable, requests: 3},
+		{status: http.StatusBadRequest, requests: 1},
+		{status: http.StatusNotFound, requests: 1},
+	}
+	for _, c := range cases {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(c.status)
+		}))
+
+		client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL), WithRetries(2))
+		client.sleep = func(context.Context, time.Duration) error { return nil }
+		if _, err := client.Download(context.Background(), client.URL("pokemon/1/")); err == nil {
+			t.Errorf("%d: expected an error", c.status)
+		}
+		if requests != c.requests {
+			t.Errorf("%d: expected %d requests, got %d", c.status, c.requests, requests)
+		}
+		server.Close()
+	}
+}
+
+func TestDownloadRetriesNetworkErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL), WithRetries(2))
+	retries := 0
+	client.sleep = func(context.Context, time.Duration) error {
+		retries++
+		return nil
+	}
+	if _, err := client.Download(context.Background(), client.URL("pokemon/1/")); err == nil {
+		t.Errorf("expected an error from a closed server")
+	}
+	if retries != 2 {
+		t.Errorf("expected 2 retries, got %d", retries)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	clock := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := NewLimiter(clock, 10, 2)
+
+	//the burst goes through straight away, then one every 100ms
+	waits := []time.Duration{limiter.reserve(), limiter.reserve(), limiter.reserve(), limiter.reserve()}
+	expected := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	if !slices.Equal(waits, expected) {
+		t.Errorf("expected the burst not to wait and the rest to queue up, got %v", waits)
+	}
+
+	//tokens refill as time passes, up to the burst
+	clock.Advance(time.Second)
+	if wait := limiter.reserve(); wait != 0 {
+		t.Errorf("expected the burst to be back after a second, got %v", wait)
+	}
+
+	if wait := NewLimiter(clock, 0, 0).reserve(); wait != 0 {
+		t.Errorf("expected no limit at rate 0, got %v", wait)
+	}
+}
+
+func TestDownloadCancel(t *testing.T) {
+	arrived := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	clock := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL), WithClock(clock))
+	client.sleep = func(context.Context, time.Duration) error {
+		t.Errorf("expected cancelling not to wait for retries")
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+
+	if _, err := client.Get(ctx, client.URL("pokemon/1/")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+
+	//a cancelled context doesn't wait for the limiter either, even though
+	//the fake clock never lets it through
+	limiter := NewLimiter(clock, 1, 1)
+	limiter.reserve()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the limiter to give up, got %v", err)
+	}
+}
+
+// etagAPI serves one resource with an ETag, answering 304 when it matches
+func etagAPI(t *testing.T, body *string, requests, notModified *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		etag := `"` + *body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			*notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(*body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeCache is a cache with a 5 minute TTL on a fake clock, which the
+// client has to share
+func fakeCache() (*pokecache.Cache, *clocktest.Fake) {
+	clock := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return pokecache.NewCache(5*time.Minute, pokecache.WithClock(clock), pokecache.WithStaleFor(24*time.Hour)), clock
+}
+
+func TestGetRevalidates(t *testing.T) {
+	body, requests, notModified := "v1", 0, 0
+	server := etagAPI(t, &body, &requests, &notModified)
+	cache, clock := fakeCache()
+	client := NewClient(cache, WithClock(clock), WithBaseURL(server.URL))
+	url := client.URL("pokemon/1/")
+
+	if _, err := client.Get(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Minute)
+
+	got, err := client.Get(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "v1" || requests != 2 || notModified != 1 {
+		t.Errorf("expected a 304 on the second request, got %s after %d requests and %d 304s", got, requests, notModified)
+	}
+
+	//the 304 made the entry fresh again
+	if _, err := client.Get(context.Background(), url); err != nil || requests != 2 {
+		t.Errorf("expected the refreshed entry to be used, got %d requests (%v)", requests, err)
+	}
+}
+
+func TestGetStaleWhileRevalidate(t *testing.T) {
+	body, requests, notModified := "v1", 0, 0
+	server := etagAPI(t, &body, &requests, &notModified)
+	cache, clock := fakeCache()
+	client := NewClient(cache, WithClock(clock), WithBaseURL(server.URL), WithStaleWhileRevalidate(time.Hour))
+	url := client.URL("pokemon/1/")
+
+	if _, err := client.Get(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	body = "v2"
+	clock.Advance(10 * time.Minute)
+
+	got, err := client.Get(context.Background(), url)
+	if err != nil || string(got) != "v1" {
+		t.Errorf("expected the stale body straight away, got %s (%v)", got, err)
+	}
+	client.background.Wait()
+
+	got, _ = client.Get(context.Background(), url)
+	if string(got) != "v2" || requests != 2 {
+		t.Errorf("expected the background request to update the cache, got %s after %d requests", got, requests)
+	}
+}
+
+func TestGetStaleWhileRevalidateQuietly(t *testing.T) {
+	body, requests, notModified := "v1", 0, 0
+	server := etagAPI(t, &body, &requests, &notModified)
+	cache, clock := fakeCache()
+	client := NewClient(cache, WithClock(clock), WithBaseURL(server.URL), WithRetries(0), WithStaleWhileRevalidate(time.Hour))
+	url := client.URL("pokemon/1/")
+
+	if _, err := client.Get(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	clock.Advance(10 * time.Minute)
+
+	var log bytes.Buffer
+	client.Log = &log
+	if _, err := client.Get(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	client.background.Wait()
+
+	//only the foreground request is logged, not the failed one behind it
+	if want := "Using cached data for: " + url + " (revalidating)\n"; log.String() != want {
+		t.Errorf("expected only %q in the log, got %q", want, log.String())
+	}
+}
+
+func TestGetStaleWhenOffline(t *testing.T) {
+	body, requests, notModified := "v1", 0, 0
+	server := etagAPI(t, &body, &requests, &notModified)
+	cache, clock := fakeCache()
+	client := NewClient(cache, WithClock(clock), WithBaseURL(server.URL), WithRetries(0))
+	url := client.URL("pokemon/1/")
+
+	if _, err := client.Get(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	clock.Advance(10 * time.Minute)
+
+	got, err := client.Get(context.Background(), url)
+	if err != nil || string(got) != "v1" {
+		t.Errorf("expected the stale body when the API is down, got %s (%v)", got, err)
+	}
+	if _, err := client.Get(context.Background(), client.URL("pokemon/2/")); err == nil {
+		t.Errorf("expected an error for something never cached")
+	}
+}
+
+func TestPrefetcher(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, most, requests := 0, 0, 0
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		requests++
+		most = max(most, inFlight)
+		mutex.Unlock()
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(server.URL), WithRateLimiter(NewLimiter(clock.Real, 0, 0)))
+	prefetcher := NewPrefetcher(client)
+
+	//a batch that is cancelled by the next one before it gets anywhere
+	prefetcher.Warm(2, client.URL("pokemon/100/"), client.URL("pokemon/101/"), client.URL("pokemon/102/"))
+	var urls []string
+	for id := range 10 {
+		urls = append(urls, client.URL(fmt.Sprintf("pokemon/%d/", id)))
+	}
+	prefetcher.Warm(3, urls...)
+	close(release)
+	prefetcher.Wait()
+
+	if most > 3 {
+		t.Errorf("expected at most 3 requests at a time, got %d", most)
+	}
+	for _, url := range urls {
+		if _, err := client.Get(context.Background(), url); err != nil {
+			t.Errorf("%s: %v", url, err)
+		}
+	}
+	if requests > len(urls)+2 {
+		t.Errorf("expected the first batch to be cancelled, got %d requests", requests)
+	}
+
+	//nothing happens offline, not even against the API the URLs point at
+	offlineRequests := 0
+	offlineAPI := fakeAPI(t, &offlineRequests)
+	offline := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(offlineAPI.URL), WithMirror(NewMirror(t.TempDir()), true))
+	offlinePrefetcher := NewPrefetcher(offline)
+	offlinePrefetcher.Warm(3, offline.URL("location-area/"), offline.URL("location-area/1/"))
+	offlinePrefetcher.Wait()
+	if offlinePrefetcher.current != nil || offlineRequests != 0 || offline.cache.Len() != 0 {
+		t.Errorf("expected nothing to be fetched offline, got a batch with %d requests and %d cached", offlineRequests, offline.cache.Len())
+	}
+}
+
+func TestProxy(t *testing.T) {
+	requests := 0
+	upstream := fakeAPI(t, &requests)
+	proxy := httptest.NewServer(NewProxy(NewClient(pokecache.NewCache(time.Minute), WithBaseURL(upstream.URL))))
+	defer proxy.Close()
+
+	//two users on the LAN, each with their own cache
+	for range 2 {
+		client := NewClient(pokecache.NewCache(time.Minute), WithBaseURL(proxy.URL))
+		body, err := client.Get(context.Background(), client.URL("location-area/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), proxy.URL+"/api/v2/location-area/1/") || strings.Contains(string(body), upstream.URL) {
+			t.Errorf("expected URLs pointing at the proxy, got %s", body)
+		}
+		if _, err := client.Get(context.Background(), client.URL("location-area/1/")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Get(context.Background(), client.URL("location-area/99/")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound through the proxy, got %v", err)
+		}
+	}
+	//missing resources aren't cached, everything else is
+	if requests != 4 {
+		t.Errorf("expected the second user to be served from the proxy's cache, got %d upstream requests", requests)
+	}
+
+	res, err := http.Post(proxy.URL+"/api/v2/pokemon/1/", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected only GET to be proxied, got %d", res.StatusCode)
+	}
+
+	for _, path := range []string{"location-area/../../secret", "location-area/%2e%2e/1/", "location-area//1/"} {
+		res, err := http.Get(proxy.URL + "/api/v2/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected %s to be refused, got %d", path, res.StatusCode)
+		}
+	}
+	if requests != 4 {
+		t.Errorf("expected refused paths not to reach the API, got %d upstream requests", requests)
+	}
+}
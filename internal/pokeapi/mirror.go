@@ -0,0 +1,231 @@
+package pokeapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNotInDataset is returned in offline mode for anything not mirrored
+var ErrNotInDataset = errors.New("not in offline dataset")
+
+// DefaultEndpoints are mirrored by Sync when none are named. nature and
+// growth-rate are needed to catch pokemon.
+var DefaultEndpoints = []string{
+	"pokemon",
+	"pokemon-species",
+	"location-area",
+	"type",
+	"move",
+	"evolution-chain",
+	"nature",
+	"growth-rate",
+}
+
+// Mirror is a copy of PokeAPI resources on disk. Each endpoint has its full
+// resource list in <endpoint>/index.json and every resource in
+// <endpoint>/<id>/index.json, the same layout as PokeAPI's static api-data.
+type Mirror struct {
+	dir string
+}
+
+// resourceList is a page of an endpoint's resources
+type resourceList struct {
+	Count    int           `json:"count"`
+	Next     *string       `json:"next"`
+	Previous *string       `json:"previous"`
+	Results  []resourceRef `json:"results"`
+}
+
+type resourceRef struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+}
+
+// NewMirror uses dir for the mirrored data
+func NewMirror(dir string) *Mirror {
+	return &Mirror{dir: dir}
+}
+
+// Dir is where the mirror keeps its data
+func (m *Mirror) Dir() string {
+	return m.dir
+}
+
+// splitURL breaks an API URL into the API root, the endpoint and the name or
+// ID of a resource, which is empty for list requests
+func splitURL(rawURL string) (string, string, string, url.Values, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	root, rest, ok := strings.Cut(u.Path, "/api/v2/")
+	if !ok {
+		return "", "", "", nil, fmt.Errorf("%s is not a PokeAPI URL", rawURL)
+	}
+
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	//sub-resources such as pokemon/25/encounters are not mirrored
+	if len(parts) > 2 {
+		return "", "", "", nil, fmt.Errorf("%s: %w", rawURL, ErrNotInDataset)
+	}
+	id := ""
+	if len(parts) > 1 {
+		id = parts[1]
+	}
+	base := u.Scheme + "://" + u.Host + root + "/api/v2/"
+	return base, parts[0], id, u.Query(), nil
+}
+
+// idFromURL returns the last path segment of a resource URL
+func idFromURL(rawURL string) string {
+	parts := strings.Split(strings.TrimSuffix(rawURL, "/"), "/")
+	return parts[len(parts)-1]
+}
+
+func (m *Mirror) listPath(endpoint string) string {
+	return filepath.Join(m.dir, endpoint, "index.json")
+}
+
+func (m *Mirror) resourcePath(endpoint, id string) string {
+	return filepath.Join(m.dir, endpoint, id, "index.json")
+}
+
+// Get serves an API URL from the mirror. List requests are paged from the
+// full resource list the same way the API does it.
+func (m *Mirror) Get(rawURL string) ([]byte, error) {
+	base, endpoint, id, query, err := splitURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := m.readList(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rawURL, err)
+	}
+
+	if id == "" {
+		return paginate(list, base+endpoint+"/", query)
+	}
+
+	//names are looked up in the list, files are stored by ID
+	if _, err := strconv.Atoi(id); err != nil {
+		for _, ref := range list.Results {
+			if ref.Name == id {
+				id = idFromURL(ref.URL)
+				break
+			}
+		}
+	}
+
+	data, err := os.ReadFile(m.resourcePath(endpoint, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", rawURL, ErrNotInDataset)
+	}
+	return data, err
+}
+
+func (m *Mirror) readList(endpoint string) (*resourceList, error) {
+	data, err := os.ReadFile(m.listPath(endpoint))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotInDataset
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list resourceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// paginate cuts one page out of a full resource list
+func paginate(list *resourceList, listURL string, query url.Values) ([]byte, error) {
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	offset = min(max(offset, 0), len(list.Results))
+	end := min(offset+limit, len(list.Results))
+
+	page := resourceList{Count: len(list.Results), Results: list.Results[offset:end]}
+	if end < len(list.Results) {
+		next := fmt.Sprintf("%s?offset=%d&limit=%d", listURL, end, limit)
+		page.Next = &next
+	}
+	if offset > 0 {
+		previous := fmt.Sprintf("%s?offset=%d&limit=%d", listURL, max(offset-limit, 0), limit)
+		page.Previous = &previous
+	}
+	return json.Marshal(page)
+}
+
+// writeFile replaces a file in one go, so readers never see half of it
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Sync copies up to limit resources of an endpoint (all of them when limit
+// is 0) from the API into the mirror. Resources already mirrored are
+// skipped, so an interrupted sync picks up where it left off.
+func (c *Client) Sync(ctx context.Context, endpoint string, limit int, progress func(done, total int)) error {
+	if c.mirror == nil {
+		return fmt.Errorf("no mirror directory configured")
+	}
+	m := c.mirror
+
+	//one request for the whole list, PokeAPI allows very large pages
+	data, err := c.Download(ctx, c.URL(endpoint+"/?limit=100000"))
+	if err != nil {
+		return err
+	}
+	var list resourceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	list.Next, list.Previous = nil, nil
+	list.Count = len(list.Results)
+	if data, err = json.Marshal(list); err != nil {
+		return err
+	}
+	if err := writeFile(m.listPath(endpoint), data); err != nil {
+		return err
+	}
+
+	total := len(list.Results)
+	if limit > 0 && limit < total {
+		total = limit
+	}
+	for i, ref := range list.Results[:total] {
+		path := m.resourcePath(endpoint, idFromURL(ref.URL))
+		if _, err := os.Stat(path); err != nil {
+			data, err := c.Download(ctx, c.Rewrite(ref.URL))
+			if err != nil {
+				return fmt.Errorf("%s: %w", ref.URL, err)
+			}
+			if err := writeFile(path, data); err != nil {
+				return err
+			}
+		}
+		if progress != nil {
+			progress(i+1, total)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pokeapi
+
+import (
+	"context"
+	"sync"
+)
+
+// Prefetcher warms the cache in the background with what is likely to be
+// asked for next. Only the latest batch is worked on: starting a new one
+// cancels the previous, since the user has moved on.
+type Prefetcher struct {
+	client  *Client
+	mutex   sync.Mutex
+	current *batch
+}
+
+// batch is one Warm's worth of work, with its own WaitGroup so waiting on it
+// never waits on a batch that was given up on
+type batch struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPrefetcher creates a prefetcher that gets URLs through client
+func NewPrefetcher(client *Client) *Prefetcher {
+	return &Prefetcher{client: client}
+}
+
+// Warm starts getting urls with up to workers requests at a time. It returns
+// straight away. Nothing is prefetched offline, where reads are cheap anyway.
+func (p *Prefetcher) Warm(workers int, urls ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.current != nil {
+		p.current.cancel()
+		p.current = nil
+	}
+	if workers <= 0 || p.client.Offline() || len(urls) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(Quietly(context.Background()))
+	b := &batch{cancel: cancel}
+	p.current = b
+
+	queue := make(chan string)
+	for range min(workers, len(urls)) {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for url := range queue {
+				//failures don't matter, the command will try again if it needs it
+				p.client.Get(ctx, url)
+			}
+		}()
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer close(queue)
+		for _, url := range urls {
+			select {
+			case queue <- url:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop cancels the current batch and waits for its requests to finish
+func (p *Prefetcher) Stop() {
+	p.mutex.Lock()
+	b := p.current
+	p.current = nil
+	p.mutex.Unlock()
+	if b != nil {
+		b.cancel()
+		b.wg.Wait()
+	}
+}
+
+// Wait blocks until the current batch is done or cancelled
+func (p *Prefetcher) Wait() {
+	p.mutex.Lock()
+	b := p.current
+	p.mutex.Unlock()
+	if b != nil {
+		b.wg.Wait()
+	}
+}
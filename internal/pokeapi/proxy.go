@@ -0,0 +1,66 @@
+package pokeapi
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Proxy serves /api/v2/ through a client, so everyone pointing at it shares
+// one cache. URLs in responses are rewritten to point at the proxy as well.
+type Proxy struct {
+	client *Client
+}
+
+// NewProxy creates a proxy that gets everything through client
+func NewProxy(client *Client) *Proxy {
+	return &Proxy{client: client}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resource, ok := strings.CutPrefix(r.URL.Path, "/api/v2/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	//the path is decoded and nothing has cleaned it, so ".." could climb out
+	//of the API root upstream or out of the mirror's directory offline
+	if path.Clean("/"+resource) != "/"+strings.TrimSuffix(resource, "/") {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	url := p.client.URL(resource)
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	body, err := p.client.Get(r.Context(), url)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	body = []byte(strings.ReplaceAll(string(body), p.client.BaseURL(), proxyRoot(r)))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// proxyRoot is the API root as the client of the proxy sees it
+func proxyRoot(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/api/v2/"
+}
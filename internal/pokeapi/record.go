@@ -0,0 +1,58 @@
+package pokeapi
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WithRecorder saves every response the client hands out under dir, laid
+// out by FixturePath, so a session can be replayed in tests
+func WithRecorder(dir string) Option {
+	return func(c *Client) {
+		c.recordDir = dir
+	}
+}
+
+// FixturePath is where the response for rawURL is kept in a directory of
+// fixtures. The host is left out and API URLs start at /api/v2/, so
+// "https://pokeapi.co/api/v2/pokemon/25/" is api/v2/pokemon/25/index.json
+// and a query goes in the file name: api/v2/nature/index@limit=100.json.
+func FixturePath(dir, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	p := u.Path
+	if _, rest, ok := strings.Cut(p, "/api/v2/"); ok {
+		p = "/api/v2/" + rest
+	}
+	if strings.HasSuffix(p, "/") || p == "" {
+		p += "index.json"
+	}
+	//cleaning a rooted path keeps ".." from leaving dir
+	p = path.Clean("/" + p)
+
+	if u.RawQuery != "" {
+		ext := path.Ext(p)
+		p = strings.TrimSuffix(p, ext) + "@" + strings.ReplaceAll(u.RawQuery, "/", "%2F") + ext
+	}
+	return filepath.Join(dir, filepath.FromSlash(p)), nil
+}
+
+// record saves a response as a fixture when recording, best effort
+func (c *Client) record(ctx context.Context, url string, body []byte) {
+	if c.recordDir == "" {
+		return
+	}
+	path, err := FixturePath(c.recordDir, url)
+	if err == nil {
+		err = writeFile(path, body)
+	}
+	if err != nil {
+		c.logf(ctx, "Could not record %s: %v\n", url, err)
+	}
+}
@@ -0,0 +1,140 @@
+package pokeapi
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
+)
+
+const (
+	// DefaultTimeout bounds a single HTTP request
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetries is how many times a failed GET is tried again
+	DefaultRetries = 3
+	// DefaultRateLimit keeps well within PokeAPI's fair use policy
+	DefaultRateLimit = 10
+
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// Limiter is a token bucket. Every request takes a token, tokens refill at
+// a steady rate and up to burst of them can be saved up.
+type Limiter struct {
+	mutex  sync.Mutex
+	clock  clock.Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter allows rate requests per second on average, with bursts of
+// up to burst requests. A rate of 0 or less never waits. Tokens refill as
+// time passes on clk.
+func NewLimiter(clk clock.Clock, rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		clock:  clk,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clk.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (l *Limiter) reserve() time.Duration {
+	if l == nil || l.rate <= 0 {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	//going into debt makes later callers queue up behind this one
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait blocks until the limiter allows another request or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	return sleep(ctx, l.clock, l.reserve())
+}
+
+// sleep waits for d on clk unless ctx is done first
+func sleep(ctx context.Context, clk clock.Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-clk.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithTimeout bounds every HTTP request, 0 means no timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Timeout: timeout}
+	}
+}
+
+// WithRetries sets how many times a GET is tried again after a network
+// error, a 429 or a 5xx response
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithRateLimiter shares a limiter between clients. Every request a
+// client sends, including retries, waits for it.
+func WithRateLimiter(limiter *Limiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// retryable reports whether a response is worth trying again
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// backoff is the delay before retry number attempt (from 0): exponential,
+// with jitter so many clients don't retry in lockstep
+func backoff(attempt int) time.Duration {
+	d := min(baseBackoff<<attempt, maxBackoff)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter reads the Retry-After header, which is either seconds or a
+// date, counting from now
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxBackoff), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return min(max(date.Sub(now), 0), maxBackoff), true
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package pokeapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// WithStaleWhileRevalidate serves entries that expired less than d ago
+// straight from the cache and revalidates them in the background
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(c *Client) {
+		c.staleWhileRevalidate = d
+	}
+}
+
+// revalidate fetches url, conditionally when there is a cached entry with
+// validators, and updates the cache
+func (c *Client) revalidate(ctx context.Context, url string, entry pokecache.Entry) ([]byte, error) {
+	res, err := c.fetch(ctx, url, entry.Validators)
+	if err != nil {
+		return nil, err
+	}
+
+	//unchanged, the cached copy is good for another TTL
+	if res.notModified {
+		if !c.cache.Refresh(url) {
+			c.cache.AddWithValidators(url, entry.Val, entry.Validators)
+		}
+		return entry.Val, nil
+	}
+
+	c.cache.AddWithValidators(url, res.body, res.validators)
+	return res.body, nil
+}
+
+// revalidateInBackground revalidates url unless that is already under way
+func (c *Client) revalidateInBackground(ctx context.Context, url string, entry pokecache.Entry) {
+	c.revalidatingMutex.Lock()
+	defer c.revalidatingMutex.Unlock()
+	if c.revalidating[url] {
+		return
+	}
+	c.revalidating[url] = true
+
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		//the command that asked has its answer, so this isn't tied to its
+		//context, and it would print over whatever came after it
+		ctx := Quietly(context.WithoutCancel(ctx))
+		if _, err := c.revalidate(ctx, url, entry); err != nil {
+			c.logf(ctx, "Could not revalidate %s: %v\n", url, err)
+		}
+
+		c.revalidatingMutex.Lock()
+		delete(c.revalidating, url)
+		c.revalidatingMutex.Unlock()
+	}()
+}
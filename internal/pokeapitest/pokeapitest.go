@@ -0,0 +1,133 @@
+// Package pokeapitest provides a fake PokeAPI serving fixtures from disk,
+// either written by hand or recorded with the pokedex's -record flag
+package pokeapitest
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// hosts are the servers fixtures link to. Links to them are pointed at the
+// fake server, so following them stays offline.
+var hosts = []string{
+	"https://pokeapi.co/",
+	"https://raw.githubusercontent.com/",
+}
+
+// Server serves the fixtures in a directory, laid out by
+// pokeapi.FixturePath. Anything without a fixture is a 404.
+type Server struct {
+	*httptest.Server
+	dir string
+
+	mutex    sync.Mutex
+	failures map[string]int
+	holds    map[string]*hold
+	requests []string
+}
+
+// hold keeps requests for a path waiting
+type hold struct {
+	arrived  chan struct{}
+	once     sync.Once
+	released chan struct{}
+}
+
+// NewServer serves the fixtures in dir until the test is over
+func NewServer(t testing.TB, dir string) *Server {
+	t.Helper()
+	s := &Server{dir: dir, failures: make(map[string]int), holds: make(map[string]*hold)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Root is the API root to give a client, as in pokeapi.WithBaseURL
+func (s *Server) Root() string {
+	return s.URL + "/api/v2/"
+}
+
+// Fail makes requests for a path, such as "/api/v2/pokemon/25/", answer
+// with status instead of the fixture
+func (s *Server) Fail(path string, status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures[path] = status
+}
+
+// Hold makes requests for a path wait until release is called or the client
+// gives up, to test what happens during a slow request. arrived is closed
+// when the first of them comes in.
+func (s *Server) Hold(path string) (arrived <-chan struct{}, release func()) {
+	h := &hold{arrived: make(chan struct{}), released: make(chan struct{})}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.holds[path] = h
+
+	var once sync.Once
+	return h.arrived, func() { once.Do(func() { close(h.released) }) }
+}
+
+// Requests lists the paths requested so far, queries included
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	status, failing := s.failures[r.URL.Path]
+	h, held := s.holds[r.URL.Path]
+	s.mutex.Unlock()
+
+	if held {
+		h.once.Do(func() { close(h.arrived) })
+		select {
+		case <-h.released:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if failing {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	path, err := pokeapi.FixturePath(s.dir, r.URL.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if filepath.Ext(path) == ".json" {
+		for _, host := range hosts {
+			body = []byte(strings.ReplaceAll(string(body), host, s.URL+"/"))
+		}
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Write(body)
+}
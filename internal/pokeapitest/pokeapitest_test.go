@@ -0,0 +1,87 @@
+package pokeapitest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+func newClient(root string, opts ...pokeapi.Option) *pokeapi.Client {
+	opts = append([]pokeapi.Option{pokeapi.WithBaseURL(root), pokeapi.WithRetries(0)}, opts...)
+	return pokeapi.NewClient(pokecache.NewCache(time.Minute), opts...)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var live *httptest.Server
+	live = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/api/v2/nature/?limit=100":
+			w.Write([]byte(`{"results": [{"name": "hardy", "url": "https://pokeapi.co/api/v2/nature/1/"}]}`))
+		case "/api/v2/nature/1/":
+			w.Write([]byte(`{"name": "hardy"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer live.Close()
+
+	dir := t.TempDir()
+	recording := newClient(live.URL, pokeapi.WithRecorder(dir))
+	for _, path := range []string{"nature/?limit=100", "nature/1/", "nature/2/"} {
+		recording.Get(context.Background(), recording.URL(path))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "api", "v2", "nature", "index@limit=100.json")); err != nil {
+		t.Errorf("expected the list to be recorded with its query: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "api", "v2", "nature", "2", "index.json")); err == nil {
+		t.Errorf("expected a 404 not to be recorded")
+	}
+
+	fake := NewServer(t, dir)
+	client := newClient(fake.Root())
+	body, err := client.Get(context.Background(), client.URL("nature/?limit=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), fake.URL+"/api/v2/nature/1/") {
+		t.Errorf("expected links to point at the fake server, got %s", body)
+	}
+	if _, err := client.Get(context.Background(), client.URL("nature/2/")); !errors.Is(err, pokeapi.ErrNotFound) {
+		t.Errorf("expected ErrNotFound without a fixture, got %v", err)
+	}
+
+	fake.Fail("/api/v2/nature/1/", http.StatusServiceUnavailable)
+	if _, err := client.Get(context.Background(), client.URL("nature/1/")); err == nil || errors.Is(err, pokeapi.ErrNotFound) {
+		t.Errorf("expected the failure to be served, got %v", err)
+	}
+	if requests := fake.Requests(); len(requests) != 3 || requests[0] != "/api/v2/nature/?limit=100" {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+}
+
+func TestFixturePath(t *testing.T) {
+	cases := map[string]string{
+		"https://pokeapi.co/api/v2/pokemon/25/":                           "api/v2/pokemon/25/index.json",
+		"http://localhost:8000/mirror/api/v2/location-area/?offset=20":    "api/v2/location-area/index@offset=20.json",
+		"https://raw.githubusercontent.com/PokeAPI/sprites/master/25.png": "PokeAPI/sprites/master/25.png",
+		"http://localhost:8000/api/v2/../../../etc/passwd":                "etc/passwd",
+	}
+	for url, expected := range cases {
+		path, err := pokeapi.FixturePath("fixtures", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != filepath.Join("fixtures", filepath.FromSlash(expected)) {
+			t.Errorf("%s: got %s, expected %s", url, path, expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package pokecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// WithCompression gzips values of at least threshold bytes. Get and Lookup
+// hand them back uncompressed. 0 turns compression off.
+func WithCompression(threshold int) Option {
+	return func(c *Cache) {
+		c.compressAbove = threshold
+	}
+}
+
+// encode compresses val when it is big enough and it actually helps
+func (c *Cache) encode(val []byte) ([]byte, bool) {
+	if c.compressAbove <= 0 || len(val) < c.compressAbove {
+		return val, false
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(val); err != nil {
+		return val, false
+	}
+	if err := zw.Close(); err != nil || buf.Len() >= len(val) {
+		return val, false
+	}
+	return buf.Bytes(), true
+}
+
+// decode returns an entry's value as it was added
+func (e cacheEntry) decode() ([]byte, bool) {
+	if !e.compressed {
+		return e.val, true
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(e.val))
+	if err != nil {
+		return nil, false
+	}
+	val, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// TierStats describes what one tier of the cache holds
+type TierStats struct {
+	Entries    int
+	Compressed int
+	// RawBytes is the size of the values as added, StoredBytes after compression
+	RawBytes    int64
+	StoredBytes int64
+}
+
+func (t *TierStats) count(e cacheEntry) {
+	t.Entries++
+	if e.compressed {
+		t.Compressed++
+	}
+	t.RawBytes += int64(e.rawSize)
+	t.StoredBytes += int64(len(e.val))
+}
+
+// Stats describes the memory and disk tiers of the cache
+type Stats struct {
+	Memory TierStats
+	Disk   TierStats
+}
+
+// Stats adds up the entries of both tiers, stale ones included
+func (c *Cache) Stats() Stats {
+	var stats Stats
+	for _, s := range c.shards {
+		s.mutex.RLock()
+		for _, e := range s.entries {
+			stats.Memory.count(e)
+		}
+		s.mutex.RUnlock()
+	}
+	if c.disk != nil {
+		c.disk.each(func(_ string, e cacheEntry) {
+			stats.Disk.count(e)
+		})
+	}
+	return stats
+}
@@ -0,0 +1,181 @@
+package pokecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WithDiskDir keeps a copy of every entry in dir, so the cache outlives the
+// process and entries evicted from memory can come back. The disk tier is
+// best effort: entries that can't be written are only kept in memory.
+func WithDiskDir(dir string) Option {
+	return func(c *Cache) {
+		c.disk = &diskTier{dir: dir}
+	}
+}
+
+// diskTier stores one file per entry: a JSON header line, then the value
+// as it is stored in memory. The file's modification time is when the
+// entry expires, which lets reaping skip reading the files.
+type diskTier struct {
+	dir string
+	//next is the bucket the next reap starts at
+	next atomic.Uint32
+}
+
+const diskExt = ".entry"
+
+// Entries are spread over diskBuckets subdirectories by the first byte of
+// their hash. A reap only looks at diskBucketsPerReap of them, so a big
+// cache is gone over in pieces instead of walked in full every time.
+const (
+	diskBuckets        = 256
+	diskBucketsPerReap = 16
+)
+
+type diskHeader struct {
+	Key        string       `json:"key"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	TTL        jsonDuration `json:"ttl"`
+	Validators Validators   `json:"validators"`
+	Compressed bool         `json:"compressed,omitempty"`
+	RawSize    int          `json:"raw_size"`
+}
+
+// jsonDuration is a time.Duration that reads and writes like "5m0s" in JSON
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	*d = jsonDuration(parsed)
+	return err
+}
+
+func (d *diskTier) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.dir, name[:2], name+diskExt)
+}
+
+func (d *diskTier) write(key string, e cacheEntry) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	header, err := json.Marshal(diskHeader{
+		Key:        key,
+		CreatedAt:  e.createdAt,
+		ExpiresAt:  e.expiresAt,
+		TTL:        jsonDuration(e.ttl),
+		Validators: e.validators,
+		Compressed: e.compressed,
+		RawSize:    e.rawSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	//write to a temporary file first so a crash never leaves half an entry
+	tmp := path + ".tmp"
+	data := append(append(header, '\n'), e.val...)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp, e.expiresAt, e.expiresAt); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (d *diskTier) read(key string) (cacheEntry, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	readKey, e, err := decodeDiskEntry(data)
+	if err != nil || readKey != key {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+func decodeDiskEntry(data []byte) (string, cacheEntry, error) {
+	line, val, _ := bytes.Cut(data, []byte{'\n'})
+	var h diskHeader
+	if err := json.Unmarshal(line, &h); err != nil {
+		return "", cacheEntry{}, err
+	}
+	return h.Key, cacheEntry{
+		createdAt:  h.CreatedAt,
+		expiresAt:  h.ExpiresAt,
+		ttl:        time.Duration(h.TTL),
+		val:        val,
+		validators: h.Validators,
+		compressed: h.Compressed,
+		rawSize:    h.RawSize,
+	}, nil
+}
+
+// walk calls fn with the path and modification time of every entry file
+func (d *diskTier) walk(fn func(path string, info fs.FileInfo)) {
+	filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, diskExt) {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			fn(path, info)
+		}
+		return nil
+	})
+}
+
+// each calls fn with every entry on disk
+func (d *diskTier) each(fn func(key string, e cacheEntry)) {
+	d.walk(func(path string, _ fs.FileInfo) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		if key, e, err := decodeDiskEntry(data); err == nil {
+			fn(key, e)
+		}
+	})
+}
+
+// reap removes entries of the next few buckets that expired more than
+// staleFor before now
+func (d *diskTier) reap(now time.Time, staleFor time.Duration) {
+	for range diskBucketsPerReap {
+		bucket := filepath.Join(d.dir, fmt.Sprintf("%02x", (d.next.Add(1)-1)%diskBuckets))
+		files, err := os.ReadDir(bucket)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), diskExt) {
+				continue
+			}
+			if info, err := file.Info(); err == nil && now.After(info.ModTime().Add(staleFor)) {
+				os.Remove(filepath.Join(bucket, file.Name()))
+			}
+		}
+	}
+}
@@ -1,83 +1,395 @@
 package pokecache
 
 import (
+	"container/list"
+	"hash/maphash"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clock"
 )
 
+// Validators let a cached response be revalidated with a conditional
+// request instead of downloading it again
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Entry is a cached value along with what is needed to revalidate it
+type Entry struct {
+	Val        []byte
+	Validators Validators
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Stale reports whether the entry has outlived its TTL at now
+func (e Entry) Stale(now time.Time) bool {
+	return now.After(e.ExpiresAt)
+}
+
 type cacheEntry struct {
-	createdAt time.Time
-	val       []byte
+	createdAt  time.Time
+	expiresAt  time.Time
+	ttl        time.Duration
+	val        []byte
+	validators Validators
+	compressed bool
+	rawSize    int
 }
 
+// Cache spreads its entries over shards, each with its own lock, so
+// concurrent readers and writers rarely wait for each other
 type Cache struct {
-	entries  map[string]cacheEntry
-	mutex    sync.Mutex
-	interval time.Duration
+	shards        []*shard
+	seed          maphash.Seed
+	count         atomic.Int64
+	interval      time.Duration
+	reapInterval  time.Duration
+	policy        func(key string) time.Duration
+	maxEntries    int
+	staleFor      time.Duration
+	compressAbove int
+	disk          *diskTier
+	clock         clock.Clock
 }
 
-func NewCache(interval time.Duration) *Cache {
+// Option configures a Cache
+type Option func(*Cache)
+
+// WithMaxEntries limits the cache to n entries, 0 means no limit. Each
+// shard holds its share of them and makes room for a new entry by dropping
+// its own oldest one, so adds never look beyond their shard. Keys don't hash
+// evenly, so the limit is approximate: a shard can be full while the cache
+// has room. Caches too small to give every shard minShardEntries get fewer
+// shards, down to one, where the limit is exact and the oldest entry goes.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithStaleFor keeps expired entries around for d longer. Get no longer
+// returns them, but Lookup does, so they can be revalidated or served when
+// there is nothing better.
+func WithStaleFor(d time.Duration) Option {
+	return func(c *Cache) {
+		c.staleFor = d
+	}
+}
+
+// defaultShards is plenty for a handful of goroutines on a handful of cores
+const defaultShards = 32
+
+// minShardEntries is the smallest share of a limited cache a shard gets, so
+// uneven hashing doesn't leave the busiest shards evicting early
+const minShardEntries = 64
+
+type shard struct {
+	mutex   sync.RWMutex
+	entries map[string]cacheEntry
+	//order has the keys from the least to the most recently stored
+	order    *list.List
+	elements map[string]*list.Element
+	limit    int
+}
+
+// put stores an entry as the newest in the shard and reports whether the
+// key is new. The shard must be locked for writing.
+func (s *shard) put(key string, entry cacheEntry) bool {
+	s.entries[key] = entry
+	if e, ok := s.elements[key]; ok {
+		s.order.MoveToBack(e)
+		return false
+	}
+	s.elements[key] = s.order.PushBack(key)
+	return true
+}
+
+// remove drops a key. The shard must be locked for writing.
+func (s *shard) remove(key string) {
+	delete(s.entries, key)
+	if e, ok := s.elements[key]; ok {
+		s.order.Remove(e)
+		delete(s.elements, key)
+	}
+}
+
+// WithShards sets how many independently locked parts the cache has
+func WithShards(n int) Option {
+	return func(c *Cache) {
+		c.shards = make([]*shard, max(n, 1))
+	}
+}
+
+// WithReapInterval sets how often expired entries are removed, by default
+// as often as the cache's TTL
+func WithReapInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		c.reapInterval = d
+	}
+}
+
+// WithTTLPolicy decides the TTL of each key added with Add. A policy
+// returning 0 leaves the key with the cache's TTL.
+func WithTTLPolicy(policy func(key string) time.Duration) Option {
+	return func(c *Cache) {
+		c.policy = policy
+	}
+}
+
+// TTLRule gives keys matching Pattern their own TTL
+type TTLRule struct {
+	Pattern *regexp.Regexp
+	TTL     time.Duration
+}
+
+// WithTTLRules is a TTL policy where the first matching rule wins
+func WithTTLRules(rules ...TTLRule) Option {
+	return WithTTLPolicy(func(key string) time.Duration {
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(key) {
+				return rule.TTL
+			}
+		}
+		return 0
+	})
+}
+
+// WithClock makes the cache tell the time with c, such as a fake clock in tests
+func WithClock(c clock.Clock) Option {
+	return func(cache *Cache) {
+		cache.clock = c
+	}
+}
+
+// NewCache creates a cache whose entries expire after interval unless a
+// TTL policy or AddWithTTL says otherwise
+func NewCache(interval time.Duration, opts ...Option) *Cache {
 	cache := &Cache{
-		entries:  make(map[string]cacheEntry),
-		interval: interval,
+		shards:       make([]*shard, defaultShards),
+		seed:         maphash.MakeSeed(),
+		interval:     interval,
+		reapInterval: interval,
+		clock:        clock.Real,
+	}
+	for _, opt := range opts {
+		opt(cache)
+	}
+	//every shard needs room for a fair share of the entries
+	if cache.maxEntries > 0 {
+		cache.shards = cache.shards[:min(len(cache.shards), max(cache.maxEntries/minShardEntries, 1))]
+	}
+	for i := range cache.shards {
+		cache.shards[i] = &shard{
+			entries:  make(map[string]cacheEntry),
+			order:    list.New(),
+			elements: make(map[string]*list.Element),
+		}
+		//the limit is shared out, any remainder going to the first shards
+		if cache.maxEntries > 0 {
+			cache.shards[i].limit = cache.maxEntries / len(cache.shards)
+			if i < cache.maxEntries%len(cache.shards) {
+				cache.shards[i].limit++
+			}
+		}
 	}
 
 	//start the reaping goroutine
-	go cache.reapLoop()
+	go cache.reapLoop(cache.clock.NewTicker(cache.reapInterval))
 
 	return cache
 }
 
 func (c *Cache) Add(key string, val []byte) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.AddWithValidators(key, val, Validators{})
+}
 
+// AddWithTTL caches val for ttl instead of the cache's TTL
+func (c *Cache) AddWithTTL(key string, val []byte, ttl time.Duration) {
+	c.add(key, val, Validators{}, ttl)
+}
+
+// AddWithValidators caches val along with the validators of the response
+// it came from
+func (c *Cache) AddWithValidators(key string, val []byte, validators Validators) {
+	c.add(key, val, validators, 0)
+}
+
+// ttlFor picks the TTL of a key without one of its own
+func (c *Cache) ttlFor(key string) time.Duration {
+	if c.policy != nil {
+		if ttl := c.policy(key); ttl > 0 {
+			return ttl
+		}
+	}
+	return c.interval
+}
+
+func (c *Cache) add(key string, val []byte, validators Validators, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttlFor(key)
+	}
+
+	now := c.clock.Now()
+	stored, compressed := c.encode(val)
 	entry := cacheEntry{
-		createdAt: time.Now(),
-		val:       val,
+		createdAt:  now,
+		expiresAt:  now.Add(ttl),
+		ttl:        ttl,
+		val:        stored,
+		validators: validators,
+		compressed: compressed,
+		rawSize:    len(val),
 	}
+	if c.disk != nil {
+		//best effort, the entry is still good in memory
+		c.disk.write(key, entry)
+	}
+	c.store(key, entry)
+}
 
-	c.entries[key] = entry
+// store puts an entry in memory, making room for it in its shard if needed
+func (c *Cache) store(key string, entry cacheEntry) {
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.put(key, entry) {
+		c.count.Add(1)
+	}
+	for s.limit > 0 && len(s.entries) > s.limit {
+		s.remove(s.order.Front().Value.(string))
+		c.count.Add(-1)
+	}
+}
+
+// lookup finds an entry in memory, or on disk and brings it back to memory
+func (c *Cache) lookup(key string) (cacheEntry, bool) {
+	s := c.shardFor(key)
+	s.mutex.RLock()
+	entry, ok := s.entries[key]
+	s.mutex.RUnlock()
+	if ok || c.disk == nil {
+		return entry, ok
+	}
 
+	entry, ok = c.disk.read(key)
+	if !ok || c.clock.Now().After(entry.expiresAt.Add(c.staleFor)) {
+		return cacheEntry{}, false
+	}
+	c.store(key, entry)
+	return entry, true
 }
 
-func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// shardFor picks the shard a key lives in
+func (c *Cache) shardFor(key string) *shard {
+	return c.shards[maphash.String(c.seed, key)%uint64(len(c.shards))]
+}
 
-	entry, ok := c.entries[key]
+// Len is the number of entries, stale ones included
+func (c *Cache) Len() int {
+	return int(c.count.Load())
+}
 
-	if !ok {
+// Get returns a value that has not expired yet
+func (c *Cache) Get(key string) ([]byte, bool) {
+	entry, ok := c.lookup(key)
+
+	if !ok || c.clock.Now().After(entry.expiresAt) {
 		return nil, false
 	}
 
-	return entry.val, true
+	return entry.decode()
 }
 
-func (c *Cache) reapLoop() {
-	ticker := time.NewTicker(c.interval)
+// Lookup returns an entry whether it has expired or not
+func (c *Cache) Lookup(key string) (Entry, bool) {
+	entry, ok := c.lookup(key)
+	if !ok {
+		return Entry{}, false
+	}
+	val, ok := entry.decode()
+	if !ok {
+		return Entry{}, false
+	}
+	return Entry{
+		Val:        val,
+		Validators: entry.validators,
+		CreatedAt:  entry.createdAt,
+		ExpiresAt:  entry.expiresAt,
+	}, true
+}
+
+// Refresh starts an entry's TTL over, for when the server confirmed it is
+// still current. It reports whether the entry was still there.
+func (c *Cache) Refresh(key string) bool {
+	//bring it back from disk if it is only there
+	if _, ok := c.lookup(key); !ok {
+		return false
+	}
+
+	s := c.shardFor(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return false
+	}
+	now := c.clock.Now()
+	entry.createdAt = now
+	entry.expiresAt = now.Add(entry.ttl)
+	s.put(key, entry)
+	if c.disk != nil {
+		c.disk.write(key, entry)
+	}
+	return true
+}
 
+func (c *Cache) reapLoop(ticker clock.Ticker) {
 	// run forever in this goroutine
 	for {
 		//waiting for the next tick
-		<-ticker.C
-
-		//lock when modifying the map
-		c.mutex.Lock()
-
-		//get current time
-		now := time.Now()
+		<-ticker.C()
+		c.reap()
+	}
+}
 
-		//check entries in the map
+// reap removes entries that expired and are past keeping stale. It goes one
+// shard at a time and finds them under a read lock, so readers elsewhere
+// never wait and readers of the same shard only wait for the deletes.
+func (c *Cache) reap() {
+	for _, s := range c.shards {
+		c.reapShard(s, c.clock.Now())
+	}
+	if c.disk != nil {
+		c.disk.reap(c.clock.Now(), c.staleFor)
+	}
+}
 
-		for k, entry := range c.entries {
-			//if entry is older than interval, delete it
-			if now.Sub(entry.createdAt) > c.interval {
-				delete(c.entries, k)
-			}
+func (c *Cache) reapShard(s *shard, now time.Time) {
+	var expired []string
+	s.mutex.RLock()
+	for k, entry := range s.entries {
+		if now.After(entry.expiresAt.Add(c.staleFor)) {
+			expired = append(expired, k)
 		}
+	}
+	s.mutex.RUnlock()
+	if len(expired) == 0 {
+		return
+	}
 
-		c.mutex.Unlock()
-
+	//lock when modifying the map, the entries may have been refreshed since
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, k := range expired {
+		if entry, ok := s.entries[k]; ok && now.After(entry.expiresAt.Add(c.staleFor)) {
+			s.remove(k)
+			c.count.Add(-1)
+		}
 	}
 }
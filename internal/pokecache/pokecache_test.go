@@ -1,9 +1,17 @@
 package pokecache // Use the same package as your main code
 
 import (
+	"bytes"
 	"fmt"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing" // Import testing package
 	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clocktest"
 )
 
 func TestAddGet(t *testing.T) {
@@ -39,10 +47,15 @@ func TestAddGet(t *testing.T) {
 	}
 }
 
+// newFakeCache creates a cache on a fake clock
+func newFakeCache(ttl time.Duration, opts ...Option) (*Cache, *clocktest.Fake) {
+	clock := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return NewCache(ttl, append(opts, WithClock(clock))...), clock
+}
+
 func TestReapLoop(t *testing.T) {
 	const baseTime = 5 * time.Millisecond
-	const waitTime = baseTime + 5*time.Millisecond
-	cache := NewCache(baseTime)
+	cache, clock := newFakeCache(baseTime)
 	cache.Add("https://example.com", []byte("testdata"))
 
 	_, ok := cache.Get("https://example.com")
@@ -51,11 +64,332 @@ func TestReapLoop(t *testing.T) {
 		return
 	}
 
-	time.Sleep(waitTime)
+	//the reaping goroutine wakes up on the fake clock's tick
+	clock.Advance(baseTime + 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := cache.Lookup("https://example.com"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("expected to not find key")
+			return
+		}
+		runtime.Gosched()
+	}
+}
 
-	_, ok = cache.Get("https://example.com")
-	if ok {
-		t.Errorf("expected to not find key")
-		return
+func TestMaxEntries(t *testing.T) {
+	//with one shard the oldest entry in the whole cache goes
+	cache, clock := newFakeCache(time.Minute, WithMaxEntries(2), WithShards(1))
+	cache.Add("https://example.com/1", []byte("one"))
+	clock.Advance(time.Millisecond)
+	cache.Add("https://example.com/2", []byte("two"))
+	clock.Advance(time.Millisecond)
+	cache.Add("https://example.com/3", []byte("three"))
+
+	if _, ok := cache.Get("https://example.com/1"); ok {
+		t.Errorf("expected the oldest entry to be dropped")
+	}
+	for _, key := range []string{"https://example.com/2", "https://example.com/3"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected to find %s", key)
+		}
+	}
+
+	//otherwise every shard keeps its share and never holds more
+	limit := 4*minShardEntries + 2
+	cache, _ = newFakeCache(time.Minute, WithMaxEntries(limit), WithShards(4))
+	for i := range 10 * limit {
+		cache.Add(fmt.Sprintf("https://example.com/%d", i), []byte("data"))
+	}
+	for _, s := range cache.shards {
+		if len(s.entries) > s.limit || s.limit < minShardEntries || s.limit > minShardEntries+1 {
+			t.Errorf("expected %d or %d entries per shard, got %d of %d", minShardEntries, minShardEntries+1, len(s.entries), s.limit)
+		}
+	}
+	if n := cache.Len(); n > limit {
+		t.Errorf("expected at most %d entries, got %d", limit, n)
+	}
+
+	//a small cache isn't split up, so it keeps as many keys as it was told
+	cache, _ = newFakeCache(time.Minute, WithMaxEntries(40))
+	for i := range 40 {
+		cache.Add(fmt.Sprintf("https://example.com/%d", i), []byte("data"))
+	}
+	for i := range 40 {
+		if _, ok := cache.Get(fmt.Sprintf("https://example.com/%d", i)); !ok {
+			t.Errorf("expected all 40 entries to be kept, %d was dropped", i)
+		}
+	}
+}
+
+func TestStaleEntries(t *testing.T) {
+	cache, clock := newFakeCache(5*time.Millisecond, WithStaleFor(time.Hour))
+	cache.AddWithValidators("https://example.com", []byte("testdata"), Validators{ETag: `"abc"`})
+
+	clock.Advance(10 * time.Millisecond)
+	cache.reap()
+
+	if _, ok := cache.Get("https://example.com"); ok {
+		t.Errorf("expected Get to skip an expired entry")
+	}
+	entry, ok := cache.Lookup("https://example.com")
+	if !ok || !entry.Stale(clock.Now()) || entry.Validators.ETag != `"abc"` {
+		t.Errorf("expected Lookup to return the stale entry, got %+v", entry)
+	}
+
+	if !cache.Refresh("https://example.com") {
+		t.Fatalf("expected to refresh the entry")
+	}
+	if _, ok := cache.Get("https://example.com"); !ok {
+		t.Errorf("expected the refreshed entry to be fresh")
+	}
+
+	clock.Advance(2 * time.Hour)
+	cache.reap()
+	if _, ok := cache.Lookup("https://example.com"); ok {
+		t.Errorf("expected the entry to be reaped once past keeping stale")
+	}
+}
+
+func TestPerKeyTTL(t *testing.T) {
+	cache, clock := newFakeCache(time.Minute, WithTTLRules(
+		TTLRule{Pattern: regexp.MustCompile(`/pokemon-species/`), TTL: 24 * time.Hour},
+		TTLRule{Pattern: regexp.MustCompile(`/pokemon/`), TTL: time.Hour},
+	))
+	cache.Add("https://example.com/pokemon-species/1/", []byte("species"))
+	cache.Add("https://example.com/pokemon/1/", []byte("pokemon"))
+	cache.Add("https://example.com/location-area/", []byte("list"))
+	cache.AddWithTTL("https://example.com/location-area/1/", []byte("area"), 10*time.Second)
+
+	cases := []struct {
+		after time.Duration
+		found []string
+	}{
+		{after: 5 * time.Second, found: []string{"species", "pokemon", "list", "area"}},
+		{after: 30 * time.Second, found: []string{"species", "pokemon", "list"}},
+		{after: 2 * time.Minute, found: []string{"species", "pokemon"}},
+		{after: 2 * time.Hour, found: []string{"species"}},
+		{after: 48 * time.Hour, found: nil},
+	}
+	keys := map[string]string{
+		"species": "https://example.com/pokemon-species/1/",
+		"pokemon": "https://example.com/pokemon/1/",
+		"list":    "https://example.com/location-area/",
+		"area":    "https://example.com/location-area/1/",
+	}
+
+	start := clock.Now()
+	for _, c := range cases {
+		clock.Advance(start.Add(c.after).Sub(clock.Now()))
+		cache.reap()
+		for name, key := range keys {
+			_, ok := cache.Get(key)
+			if ok != slices.Contains(c.found, name) {
+				t.Errorf("after %s: expected %s found=%v, got %v", c.after, name, !ok, ok)
+			}
+		}
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	cache, clock := newFakeCache(time.Minute, WithMaxEntries(50))
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				key := fmt.Sprintf("https://example.com/%d/%d", g, i%100)
+				cache.Add(key, []byte("data"))
+				cache.Get(key)
+				cache.Refresh(key)
+				if i%50 == 0 {
+					clock.Advance(time.Second)
+					cache.reap()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	//shards evict under the same lock they add with, so the limit holds
+	if n := cache.Len(); n > 50 {
+		t.Errorf("expected at most 50 entries, got %d", n)
+	}
+}
+
+// benchmarkKeys are URLs like the ones the pokedex caches
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%d/", i)
+	}
+	return keys
+}
+
+// benchmarkCache runs body on every CPU against caches with one shard, which
+// is a single lock like the cache used to have, and with the default shards
+func benchmarkCache(b *testing.B, body func(cache *Cache, keys []string, i int)) {
+	keys := benchmarkKeys(1024)
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			cache := NewCache(time.Hour, WithShards(shards))
+			for _, key := range keys {
+				cache.Add(key, []byte("data"))
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					body(cache, keys, i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	benchmarkCache(b, func(cache *Cache, keys []string, i int) {
+		cache.Get(keys[i%len(keys)])
+	})
+}
+
+func BenchmarkMixedParallel(b *testing.B) {
+	benchmarkCache(b, func(cache *Cache, keys []string, i int) {
+		key := keys[(i*7)%len(keys)]
+		if i%10 == 0 {
+			cache.Add(key, []byte("data"))
+		} else {
+			cache.Get(key)
+		}
+	})
+}
+
+// BenchmarkAddFullParallel adds to a cache at its limit, so every add evicts
+func BenchmarkAddFullParallel(b *testing.B) {
+	keys := benchmarkKeys(4096)
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			cache := NewCache(time.Hour, WithShards(shards), WithMaxEntries(len(keys)/4))
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.Add(keys[i%len(keys)], []byte("data"))
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkGetWhileReaping(b *testing.B) {
+	benchmarkCache(b, func(cache *Cache, keys []string, i int) {
+		if i%100 == 0 {
+			cache.reap()
+		}
+		cache.Get(keys[i%len(keys)])
+	})
+}
+
+func TestCompression(t *testing.T) {
+	cache, _ := newFakeCache(time.Minute, WithCompression(1024))
+	big := []byte(strings.Repeat(`{"name": "pikachu", "url": "https://pokeapi.co/api/v2/pokemon/25/"}`, 100))
+	cache.Add("https://example.com/big", big)
+	cache.Add("https://example.com/small", []byte("small"))
+
+	for key, expected := range map[string][]byte{"https://example.com/big": big, "https://example.com/small": []byte("small")} {
+		val, ok := cache.Get(key)
+		if !ok || !bytes.Equal(val, expected) {
+			t.Errorf("%s: expected the value back as it was added", key)
+		}
+	}
+
+	stats := cache.Stats().Memory
+	if stats.Entries != 2 || stats.Compressed != 1 {
+		t.Errorf("expected only the big entry to be compressed, got %+v", stats)
+	}
+	if stats.RawBytes != int64(len(big)+len("small")) || stats.StoredBytes >= stats.RawBytes/2 {
+		t.Errorf("unexpected sizes %+v", stats)
+	}
+}
+
+func TestDiskTier(t *testing.T) {
+	dir := t.TempDir()
+	cache, clock := newFakeCache(time.Minute, WithDiskDir(dir), WithCompression(16), WithMaxEntries(1))
+	cache.AddWithValidators("https://example.com/1", []byte(strings.Repeat("one ", 100)), Validators{ETag: `"1"`})
+	cache.Add("https://example.com/2", []byte("two"))
+
+	//the first entry was evicted from memory, but not from disk
+	if stats := cache.Stats(); stats.Memory.Entries != 1 || stats.Disk.Entries != 2 || stats.Disk.Compressed != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if val, ok := cache.Get("https://example.com/1"); !ok || string(val) != strings.Repeat("one ", 100) {
+		t.Errorf("expected the evicted entry to come back from disk, got %q", val)
+	}
+
+	//a new cache on the same directory picks up where the last one left off
+	restarted := NewCache(time.Minute, WithClock(clock), WithDiskDir(dir))
+	entry, ok := restarted.Lookup("https://example.com/1")
+	if !ok || entry.Validators.ETag != `"1"` {
+		t.Errorf("expected the entry with its validators after a restart, got %+v", entry)
+	}
+
+	//reaping goes over the disk a few buckets at a time
+	clock.Advance(2 * time.Minute)
+	for range diskBuckets / diskBucketsPerReap {
+		restarted.reap()
+	}
+	if stats := restarted.Stats(); stats.Disk.Entries != 0 {
+		t.Errorf("expected expired entries to be reaped from disk, got %+v", stats.Disk)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	source, sourceClock := newFakeCache(time.Minute, WithCompression(16))
+	target, targetClock := newFakeCache(time.Minute, WithDiskDir(t.TempDir()))
+
+	//the target has an old copy of 1 and a newer copy of 2 than the source
+	target.Add("https://example.com/1", []byte("old one"))
+	target.Add("https://example.com/2", []byte("new two"))
+	sourceClock.Advance(time.Second)
+	targetClock.Advance(time.Second)
+	source.AddWithValidators("https://example.com/1", []byte(strings.Repeat("new one ", 10)), Validators{ETag: `"1"`})
+	source.Add("https://example.com/3", []byte("three"))
+	sourceClock.Advance(-time.Hour)
+	source.Add("https://example.com/2", []byte("old two"))
+
+	var buf bytes.Buffer
+	written, err := source.Snapshot(&buf)
+	if err != nil || written != 3 {
+		t.Fatalf("expected 3 entries written, got %d (%v)", written, err)
+	}
+
+	restored, err := target.Restore(&buf)
+	if err != nil || restored != 2 {
+		t.Fatalf("expected 2 entries restored, got %d (%v)", restored, err)
+	}
+	expected := map[string]string{
+		"https://example.com/1": strings.Repeat("new one ", 10),
+		"https://example.com/2": "new two",
+		"https://example.com/3": "three",
+	}
+	for key, val := range expected {
+		entry, ok := target.Lookup(key)
+		if !ok || string(entry.Val) != val {
+			t.Errorf("%s: expected %q, got %q", key, val, entry.Val)
+		}
+	}
+	if entry, _ := target.Lookup("https://example.com/1"); entry.Validators.ETag != `"1"` {
+		t.Errorf("expected validators to be restored, got %+v", entry.Validators)
+	}
+	if stats := target.Stats(); stats.Disk.Entries != 3 {
+		t.Errorf("expected restored entries on disk too, got %+v", stats.Disk)
+	}
+
+	if _, err := target.Restore(strings.NewReader("not a snapshot")); err == nil {
+		t.Errorf("expected an error for something that isn't a snapshot")
 	}
 }
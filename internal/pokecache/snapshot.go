@@ -0,0 +1,129 @@
+package pokecache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	snapshotFormat  = "pokecache-snapshot"
+	snapshotVersion = 1
+)
+
+// snapshotHeader is the first line of a snapshot
+type snapshotHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// snapshotEntry is one line per entry after the header. Values are stored
+// uncompressed, so snapshots don't depend on the cache's settings.
+type snapshotEntry struct {
+	Key        string       `json:"key"`
+	Val        []byte       `json:"val"`
+	CreatedAt  time.Time    `json:"created_at"`
+	TTL        jsonDuration `json:"ttl"`
+	Validators Validators   `json:"validators"`
+}
+
+// Snapshot writes every entry, stale ones included, as a gzipped archive
+// that Restore can read back. It returns the number of entries written.
+func (c *Cache) Snapshot(w io.Writer) (int, error) {
+	//memory may hold newer copies of entries on disk
+	entries := make(map[string]cacheEntry)
+	if c.disk != nil {
+		c.disk.each(func(key string, e cacheEntry) {
+			entries[key] = e
+		})
+	}
+	for _, s := range c.shards {
+		s.mutex.RLock()
+		for key, e := range s.entries {
+			if old, ok := entries[key]; !ok || e.createdAt.After(old.createdAt) {
+				entries[key] = e
+			}
+		}
+		s.mutex.RUnlock()
+	}
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(snapshotHeader{Format: snapshotFormat, Version: snapshotVersion, Created: c.clock.Now()}); err != nil {
+		return 0, err
+	}
+	written := 0
+	for key, e := range entries {
+		val, ok := e.decode()
+		if !ok {
+			continue
+		}
+		err := enc.Encode(snapshotEntry{
+			Key:        key,
+			Val:        val,
+			CreatedAt:  e.createdAt,
+			TTL:        jsonDuration(e.ttl),
+			Validators: e.validators,
+		})
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, zw.Close()
+}
+
+// Restore merges a snapshot into the cache. Entries only replace ones that
+// are older, so restoring never loses newer data. It returns the number of
+// entries taken from the snapshot.
+func (c *Cache) Restore(r io.Reader) (int, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("not a cache snapshot: %w", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil || header.Format != snapshotFormat {
+		return 0, fmt.Errorf("not a cache snapshot")
+	}
+	if header.Version > snapshotVersion {
+		return 0, fmt.Errorf("cache snapshot version %d is newer than this program understands", header.Version)
+	}
+
+	restored := 0
+	for {
+		var e snapshotEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, err
+		}
+
+		if existing, ok := c.lookup(e.Key); ok && !e.CreatedAt.After(existing.createdAt) {
+			continue
+		}
+		stored, compressed := c.encode(e.Val)
+		entry := cacheEntry{
+			createdAt:  e.CreatedAt,
+			expiresAt:  e.CreatedAt.Add(time.Duration(e.TTL)),
+			ttl:        time.Duration(e.TTL),
+			val:        stored,
+			validators: e.Validators,
+			compressed: compressed,
+			rawSize:    len(e.Val),
+		}
+		if c.disk != nil {
+			c.disk.write(e.Key, entry)
+		}
+		c.store(e.Key, entry)
+		restored++
+	}
+}
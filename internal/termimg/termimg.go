@@ -0,0 +1,157 @@
+package termimg
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+)
+
+// Mode is how many colours the terminal can show
+type Mode int
+
+const (
+	// TrueColor uses 24-bit colour escape codes
+	TrueColor Mode = iota
+	// Color256 uses the xterm 256 colour palette
+	Color256
+)
+
+// Options control how an image is drawn
+type Options struct {
+	// Width in terminal columns, 0 keeps the image's own width
+	Width int
+	Mode  Mode
+}
+
+// DetectMode guesses the colour support of the terminal from COLORTERM
+func DetectMode() Mode {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	return Color256
+}
+
+// Render draws img with half-block characters, two pixels per cell: the
+// upper pixel is the foreground of "▀" and the lower one the background.
+// Transparent borders are cropped away first.
+func Render(w io.Writer, img image.Image, opts Options) error {
+	bounds := crop(img)
+	if bounds.Empty() {
+		return nil
+	}
+
+	width := bounds.Dx()
+	if opts.Width > 0 {
+		width = opts.Width
+	}
+	scale := float64(bounds.Dx()) / float64(width)
+	height := int(float64(bounds.Dy()) / scale)
+
+	pixel := func(x, y int) (color.Color, bool) {
+		sx := bounds.Min.X + int(float64(x)*scale)
+		sy := bounds.Min.Y + int(float64(y)*scale)
+		if y >= height || sy >= bounds.Max.Y {
+			return nil, false
+		}
+		c := img.At(sx, sy)
+		return c, opaque(c)
+	}
+
+	out := bufio.NewWriter(w)
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x++ {
+			top, topOK := pixel(x, y)
+			bottom, bottomOK := pixel(x, y+1)
+
+			switch {
+			case topOK && bottomOK:
+				fmt.Fprintf(out, "%s%s▀", escape(38, top, opts.Mode), escape(48, bottom, opts.Mode))
+			case topOK:
+				fmt.Fprintf(out, "%s▀", escape(38, top, opts.Mode))
+			case bottomOK:
+				fmt.Fprintf(out, "%s▄", escape(38, bottom, opts.Mode))
+			default:
+				out.WriteString(" ")
+			}
+			out.WriteString("\x1b[0m")
+		}
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
+
+// crop returns the smallest rectangle holding every visible pixel
+func crop(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	var r image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if opaque(img.At(x, y)) {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func opaque(c color.Color) bool {
+	_, _, _, a := c.RGBA()
+	return a >= 0x8000
+}
+
+// escape builds the escape code setting the foreground (38) or background (48)
+func escape(layer int, c color.Color, mode Mode) string {
+	r, g, b, _ := c.RGBA()
+	r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
+
+	if mode == TrueColor {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, r8, g8, b8)
+	}
+	return fmt.Sprintf("\x1b[%d;5;%dm", layer, Xterm256(r8, g8, b8))
+}
+
+// Xterm256 returns the closest colour in the xterm palette, choosing between
+// the 6x6x6 colour cube and the 24 step grey ramp
+func Xterm256(r, g, b uint8) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v uint8) int {
+		best := 0
+		for i, l := range levels {
+			if abs(int(v)-l) < abs(int(v)-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	ri, gi, bi := nearest(r), nearest(g), nearest(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := distance(r, g, b, levels[ri], levels[gi], levels[bi])
+
+	avg := (int(r) + int(g) + int(b)) / 3
+	grey := min(max((avg-8)/10, 0), 23)
+	greyLevel := 8 + grey*10
+	greyDist := distance(r, g, b, greyLevel, greyLevel, greyLevel)
+
+	if greyDist < cubeDist {
+		return 232 + grey
+	}
+	return cube
+}
+
+func distance(r, g, b uint8, r2, g2, b2 int) int {
+	dr, dg, db := int(r)-r2, int(g)-g2, int(b)-b2
+	return dr*dr + dg*dg + db*db
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+package termimg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestXterm256(t *testing.T) {
+	cases := []struct {
+		r, g, b  uint8
+		expected int
+	}{
+		{r: 0, g: 0, b: 0, expected: 16},
+		{r: 255, g: 255, b: 255, expected: 231},
+		{r: 255, g: 0, b: 0, expected: 196},
+		{r: 128, g: 128, b: 128, expected: 244},
+	}
+
+	for _, c := range cases {
+		if actual := Xterm256(c.r, c.g, c.b); actual != c.expected {
+			t.Errorf("(%d,%d,%d): got %d, expected %d", c.r, c.g, c.b, actual, c.expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	// a 2x2 red square in the middle of a transparent 4x4 image
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 1; y < 3; y++ {
+		for x := 1; x < 3; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Render(&out, img, Options{Mode: TrueColor}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected the cropped square to take one line, got %d", len(lines))
+	}
+	cell := "\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀\x1b[0m"
+	if lines[0] != cell+cell {
+		t.Errorf("unexpected output %q", lines[0])
+	}
+
+	out.Reset()
+	if err := Render(&out, img, Options{Mode: Color256, Width: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "\x1b[38;5;196m▀\x1b[0m\n" {
+		t.Errorf("unexpected scaled 256 colour output %q", out.String())
+	}
+}
@@ -1,38 +1,113 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
-	"net/http"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
 
+	"github.com/placki-w/pokedexcli/internal/assets"
+	"github.com/placki-w/pokedexcli/internal/clock"
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
 	"github.com/placki-w/pokedexcli/internal/pokecache"
 )
 
-var commands map[string]cliCommand
-var cfg config
-var pokeCache *pokecache.Cache
-var pokedex map[string]pokemonDetails
-
 func main() {
 
+	//every setting gets a flag, empty flags fall through to the config file
+	flagValues := make(map[string]*string)
+	for _, s := range settings {
+		flagValues[s.key] = flag.String(s.flagName(), "", fmt.Sprintf("%s (default %q, or $%s)", s.description, s.def(), s.env()))
+	}
+	offline := flag.Bool("offline", false, "serve everything from the dataset downloaded with sync")
+	record := flag.String("record", "", "save every API response in this directory as a test fixture")
+	flag.Parse()
+
+	flags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if value, ok := flagValues[strings.ReplaceAll(f.Name, "-", "_")]; ok {
+			flags[strings.ReplaceAll(f.Name, "-", "_")] = *value
+		}
+	})
+
+	cfg := &config{configPath: defaultConfigPath()}
+	file, err := loadConfigFile(cfg.configPath)
+	if err != nil {
+		log.Fatalf("could not read config file %s: %v", cfg.configPath, err)
+	}
+	cfg.settings, err = resolveSettings(flags, file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	applySettings(cfg)
+
+	// Everything that needs the time asks this clock, so tests can fake it
+	cfg.clock = clock.Real
+
+	// Create a new cache that expires items after the configured TTL, 5 minutes by default
+	ttl, cacheOpts := cacheOptions(cfg.settings)
+	cache := pokecache.NewCache(ttl, append(cacheOpts, pokecache.WithClock(cfg.clock))...)
+
+	// All API requests go through the client, which uses the cache
+	cfg.client = pokeapi.NewClient(cache, append(clientOptions(cfg.settings, cfg.clock),
+		pokeapi.WithMirror(pokeapi.NewMirror(cfg.settings["data_dir"].value), *offline),
+		pokeapi.WithClock(cfg.clock),
+		pokeapi.WithRecorder(*record),
+	)...)
+
+	// Guesses what comes next and fetches it while the user reads
+	cfg.prefetcher = pokeapi.NewPrefetcher(cfg.client)
+
 	//base url for poke location area
-	cfg.nextUrl = "https://pokeapi.co/api/v2/location-area/"
+	cfg.nextUrl = cfg.client.URL("location-area/")
+	cfg.throws = make(map[string]int)
+
+	s := newSession(cfg, cache, os.Stdin, os.Stdout)
+
+	// The pokedex, the thing we want, picked up from the last session
+	s.savePath = s.settings["save_path"].value
+	s.pokedex, s.storage, err = loadPokedex(s.savePath)
+	if err != nil {
+		log.Fatalf("could not load pokedex from %s: %v", s.savePath, err)
+	}
 
-	// Create a new cache that expires items after 5 minutes
-	pokeCache = pokecache.NewCache(5 * time.Minute)
+	// Sprites and cries never change, so they get their own store on disk
+	s.assetDir = defaultAssetDir()
+	s.assets, err = assets.Open(s.assetDir)
+	if err != nil {
+		fmt.Fprintf(s.out, "could not open the asset store in %s, sprites and cries won't be kept: %v\n", s.assetDir, err)
+	}
+
+	// Other tools can use the pokedex, or just its cache, over HTTP instead of the prompt
+	switch flag.Arg(0) {
+	case "serve":
+		if err := commandServe(s, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "proxy":
+		if err := commandProxy(s, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// The pokedex, the thing we want
-	pokedex = make(map[string]pokemonDetails)
+	// Ctrl-C cancels the running command instead of killing the pokedex
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	s.run(interrupts)
+}
 
-	// Define the commands map
-	commands = map[string]cliCommand{
+// commandRegistry lists every command the prompt understands
+func commandRegistry() map[string]cliCommand {
+	return map[string]cliCommand{
 		"exit": {
 			name:        "exit",
 			description: "Exit the Pokedex",
@@ -60,54 +135,84 @@ func main() {
 		},
 		"catch": {
 			name:        "catch",
-			description: "Attempt to catch a pokemon based on chance and return details if so",
+			description: "Attempt to catch a pokemon: catch <pokemon> [--ball poke-ball|great-ball|ultra-ball|master-ball] [--nickname <name>]",
 			callback:    commandCatch,
 		},
 		"inspect": {
 			name:        "inspect",
-			description: "Reveal high level details of your captured pokemon",
+			description: "Reveal details of a caught species or one of your pokemon: inspect <pokemon|#id> [--sprite]",
 			callback:    commandInspect,
 		},
 		"pokedex": {
 			name:        "pokedex",
-			description: "list all your captured pokemon",
+			description: "List species you have caught: pokedex [--sort id|name|type|bst|caught-at] [--type <type>] [--generation <n>] [--seen]",
 			callback:    commandPokedex,
 		},
-	}
-
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("Pokedex > ")
-		scanner.Scan()
-		input := scanner.Text()
-
-		// Split the input by spaces to separate command and arguments
-		args := strings.Fields(input)
-		if len(args) == 0 {
-			fmt.Println("Please input a command.")
-			continue
-		}
-
-		commandName := strings.ToLower(args[0])
-
-		// Check if the command exists
-		cmd, ok := commands[commandName]
-		if !ok {
-			fmt.Println("Unknown command")
-			continue
-		}
-
-		// Pass any arguments after the command name
-		var cmdArgs []string
-		if len(args) > 1 {
-			cmdArgs = args[1:]
-		}
-
-		// Call the command with its arguments
-		err := cmd.callback(&cfg, pokeCache, cmdArgs...)
-		if err != nil {
-			fmt.Println(err)
-		}
+		"sprite": {
+			name:        "sprite",
+			description: "Draw a pokemon in the terminal: sprite <pokemon|#id> [--shiny] [--back] [--female] [--version <version>] [--width <n>] [--256]",
+			callback:    commandSprite,
+		},
+		"cry": {
+			name:        "cry",
+			description: "Download a pokemon's cry and show where it is saved: cry <pokemon|#id> [--legacy]",
+			callback:    commandCry,
+		},
+		"assets": {
+			name:        "assets",
+			description: "Show stored sprites and cries, or remove them: assets [prune [--all]]",
+			callback:    commandAssets,
+		},
+		"cache": {
+			name:        "cache",
+			description: "Show how much API data is cached, or share it with others: cache stats | cache export <file> | cache import <file>",
+			callback:    commandCache,
+		},
+		"sync": {
+			name:        "sync",
+			description: "Download API data for offline use: sync [endpoint...] [--limit <n>]",
+			callback:    commandSync,
+		},
+		"config": {
+			name:        "config",
+			description: "Show or change settings saved in the config file: config list | config get <key> | config set <key> <value> | config unset <key>",
+			callback:    commandConfig,
+		},
+		"battle": {
+			name:        "battle",
+			description: "Battle a wild pokemon from the explored area with your lead pokemon: battle [pokemon]",
+			callback:    commandBattle,
+		},
+		"party": {
+			name:        "party",
+			description: "List the pokemon in your party",
+			callback:    commandParty,
+		},
+		"box": {
+			name:        "box",
+			description: "List the pokemon in a PC box: box [n]",
+			callback:    commandBox,
+		},
+		"deposit": {
+			name:        "deposit",
+			description: "Move a party pokemon to the PC: deposit <#id> [box]",
+			callback:    commandDeposit,
+		},
+		"withdraw": {
+			name:        "withdraw",
+			description: "Move a pokemon from the PC to your party: withdraw <#id>",
+			callback:    commandWithdraw,
+		},
+		"release": {
+			name:        "release",
+			description: "Release one of your pokemon: release <#id>",
+			callback:    commandRelease,
+		},
+		"swap": {
+			name:        "swap",
+			description: "Swap the places of two of your pokemon: swap <#id> <#id>",
+			callback:    commandSwap,
+		},
 	}
 }
 
@@ -117,303 +222,496 @@ func cleanInput(text string) string {
 	return trimmedInput
 }
 
-func commandExit(cfg *config, cache *pokecache.Cache, area ...string) error {
-	fmt.Println("Closing the Pokedex... Goodbye!")
-	os.Exit(0)
-	return nil
+func commandExit(ctx context.Context, s *session, args ...string) error {
+	if s.prefetcher != nil {
+		s.prefetcher.Stop()
+	}
+	if err := s.save(); err != nil {
+		fmt.Fprintln(s.out, "could not save pokedex:", err)
+	}
+	fmt.Fprintln(s.out, "Closing the Pokedex... Goodbye!")
+	return errExit
 }
 
-func commandHelp(cfg *config, cache *pokecache.Cache, area ...string) error {
-	fmt.Println("Welcome to the Pokedex!")
-	fmt.Println("Usage: ")
-	fmt.Println("")
-	for _, cmd := range commands {
-		fmt.Printf("%s: %s\n", cmd.name, cmd.description)
+func commandHelp(ctx context.Context, s *session, args ...string) error {
+	fmt.Fprintln(s.out, "Welcome to the Pokedex!")
+	fmt.Fprintln(s.out, "Usage: ")
+	fmt.Fprintln(s.out, "")
+	for _, name := range s.commandNames() {
+		fmt.Fprintf(s.out, "%s: %s\n", name, s.commands[name].description)
 	}
 	return nil
 }
 
-func commandMap(cfg *config, cache *pokecache.Cache, area ...string) error {
+// fetchAreas gets one page of location areas
+func fetchAreas(ctx context.Context, client *pokeapi.Client, url string) (*responseBody, error) {
+	body, err := client.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
 
-	url := cfg.nextUrl
-	if cfg.nextUrl != "" {
-		url = cfg.nextUrl
+	var locations responseBody
+	if err := json.Unmarshal(body, &locations); err != nil {
+		return nil, err
 	}
+	return &locations, nil
+}
 
-	var body []byte
-	var err error
+// showAreas prints a page of areas and remembers where the pages around it are
+func (s *session) showAreas(locations *responseBody) {
+	for _, location := range locations.Results {
+		fmt.Fprintln(s.out, location.Name)
+	}
+	prefetchPage(s.config, *locations)
 
-	cachedData, found := cache.Get(url)
-	if found {
-		fmt.Println("Using cached data for:", url) //optional logging
-		body = cachedData
+	s.nextUrl = locations.Next
+	if locations.Previous != nil {
+		s.previousUrl = locations.Previous.(string)
 	} else {
-		//not in cache make HTTP request
-		fmt.Println("Fetching new data for:", url) //optional logging
-		res, err := http.Get(url)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		body, err = io.ReadAll(res.Body)
-		defer res.Body.Close()
-		if res.StatusCode > 299 {
-			log.Fatalf("Response failed with status code: %d and\nbody: %s\n", res.StatusCode, body)
-		}
-
-		if err != nil {
-			log.Fatal(err)
-		}
+		s.previousUrl = ""
+	}
+}
 
-		// Add to cache
-		cache.Add(url, body)
+func commandMap(ctx context.Context, s *session, args ...string) error {
 
-	}
-	var locations responseBody
-	if err = json.Unmarshal(body, &locations); err != nil {
-		return err
+	url := s.nextUrl
+	if s.nextUrl != "" {
+		url = s.nextUrl
 	}
 
-	for _, location := range locations.Results {
-		fmt.Println(location.Name)
+	if url == "" {
+		fmt.Fprintln(s.out, "you're on the last page")
+		return nil
 	}
 
-	cfg.nextUrl = locations.Next
-	if locations.Previous != nil {
-		cfg.previousUrl = locations.Previous.(string)
-	} else {
-		cfg.previousUrl = ""
+	locations, err := fetchAreas(ctx, s.client, url)
+	if err != nil {
+		return err
 	}
+	s.showAreas(locations)
 
 	return nil
 }
 
-func commandMapb(cfg *config, cache *pokecache.Cache, area ...string) error {
-	if cfg.previousUrl == "" {
-		fmt.Println("you're on the first page")
+func commandMapb(ctx context.Context, s *session, args ...string) error {
+	if s.previousUrl == "" {
+		fmt.Fprintln(s.out, "you're on the first page")
 		return nil
 	}
 
-	var body []byte
-	var err error
+	locations, err := fetchAreas(ctx, s.client, s.previousUrl)
+	if err != nil {
+		return err
+	}
+	s.showAreas(locations)
 
-	cachedData, found := cache.Get(cfg.previousUrl)
-	if found {
-		fmt.Println("Using cached data for:", cfg.previousUrl) //optional logging
-		body = cachedData
-	} else {
+	return nil
+}
 
-		//not in cache make HTTP request
-		fmt.Println("Fetching new data for:", cfg.previousUrl) //optional logging
-		res, err := http.Get(cfg.previousUrl)
-		if err != nil {
-			log.Fatal(err)
-		}
+// fetchArea gets the details of a location area by name or id
+func fetchArea(ctx context.Context, client *pokeapi.Client, areaName string) (*locationDetails, error) {
+	//use the cache or API
+	detailsUrl := client.URL("location-area/" + areaName + "/")
+	body, err := client.Get(ctx, detailsUrl)
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		return nil, notFound("unknown location area: %s", areaName)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		body, err = io.ReadAll(res.Body)
-		defer res.Body.Close()
-		if res.StatusCode > 299 {
-			log.Fatalf("Response failed with status code: %d and\nbody: %s\n", res.StatusCode, body)
-		}
+	//dump response into locationDetails struct
+	var locationData *locationDetails = &locationDetails{}
+	if err := json.Unmarshal(body, locationData); err != nil {
+		return nil, err
+	}
+	return locationData, nil
+}
 
-		if err != nil {
-			log.Fatal(err)
+// encounterLevels returns the levels each pokemon of an area is met at
+func (l locationDetails) encounterLevels() map[string]levelRange {
+	levels := make(map[string]levelRange)
+	for _, encounter := range l.PokemonEncounters {
+		r := levelRange{}
+		for _, version := range encounter.VersionDetails {
+			for _, details := range version.EncounterDetails {
+				if r.min == 0 || details.MinLevel < r.min {
+					r.min = details.MinLevel
+				}
+				if details.MaxLevel > r.max {
+					r.max = details.MaxLevel
+				}
+			}
 		}
-		// Add to cache
-		cache.Add(cfg.previousUrl, body)
+		levels[encounter.Pokemon.Name] = r
 	}
+	return levels
+}
 
-	var locations responseBody
-	if err = json.Unmarshal(body, &locations); err != nil {
-		return err
+func commandExplore(ctx context.Context, s *session, args ...string) error {
+	//check if area is provided
+	if len(args) == 0 {
+		return fmt.Errorf("missing location area name or id")
 	}
 
-	for _, location := range locations.Results {
-		fmt.Println(location.Name)
+	locationData, err := fetchArea(ctx, s.client, args[0])
+	if err != nil {
+		return err
 	}
+	fmt.Fprintf(s.out, "Exploring %s...\n", locationData.localizedName(s.language))
 
-	cfg.nextUrl = locations.Next
-	if locations.Previous != nil {
-		cfg.previousUrl = locations.Previous.(string)
+	//remember where we are so catches can record it
+	s.currentArea = locationData.Name
+	s.areaLevels = locationData.encounterLevels()
+
+	prefetchEncounters(s.config, *locationData)
+
+	//Output a list of found Pokemon
+	if len(locationData.PokemonEncounters) == 0 {
+		fmt.Fprintln(s.out, "No Pokemon found in this area.")
 	} else {
-		cfg.previousUrl = ""
+		fmt.Fprintln(s.out, "Found Pokemon:")
+		for _, encounter := range locationData.PokemonEncounters {
+			fmt.Fprintf(s.out, " - %s\n", encounter.Pokemon.Name)
+		}
 	}
 
 	return nil
 }
 
-func commandExplore(cfg *config, cache *pokecache.Cache, args ...string) error {
-	//check if area is provided
-	if len(args) == 0 {
-		return fmt.Errorf("missing location area name or id")
+// catchResult is what happened when a ball was thrown
+type catchResult struct {
+	Pokemon    string         `json:"pokemon"`
+	Ball       string         `json:"ball"`
+	Caught     bool           `json:"caught"`
+	Individual *caughtPokemon `json:"individual,omitempty"`
+	SentTo     string         `json:"sent_to,omitempty"`
+	//what the lead pokemon got out of it, one line per event
+	Experience []string `json:"experience,omitempty"`
+
+	//the lead pokemon and the experience it gets for the catch
+	leadID         int
+	leadExperience int
+}
+
+// catchTarget is everything about a pokemon the API has to be asked for
+// before a ball can be thrown at it
+type catchTarget struct {
+	pokemon *pokemonDetails
+	nature  nature
+	species *speciesDetails
+	growth  *growthRate
+}
+
+// fetchCatchTarget looks up a pokemon to catch. It only goes to the network
+// and leaves the game alone, so the server calls it without holding its lock.
+func (s *session) fetchCatchTarget(ctx context.Context, names []string) (*catchTarget, error) {
+	//resolve whatever was typed to the canonical pokemon
+	pokemonData, err := resolvePokemon(ctx, s.client, names...)
+	if err != nil {
+		return nil, err
 	}
 
-	areaName := args[0]
-	fmt.Printf("Exploring %s...\n", areaName)
+	//every pokemon has a nature from birth, pick it before it can get away
+	pokemonNature, err := rollNature(ctx, s.client, s.rand)
+	if err != nil {
+		return nil, err
+	}
+	speciesData, err := fetchSpecies(ctx, s.client, *pokemonData)
+	if err != nil {
+		return nil, err
+	}
+	growth, err := fetchGrowthRate(ctx, s.client, speciesData.GrowthRate.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &catchTarget{pokemon: pokemonData, nature: pokemonNature, species: speciesData, growth: growth}, nil
+}
 
-	//use the cache or API
-	var body []byte
-	var err error
+// catchPokemon throws a ball at a pokemon and records the outcome in the
+// pokedex and storage. finishCatch completes it.
+func (s *session) catchPokemon(target *catchTarget, ball, nickname string) *catchResult {
+	pokemonData := target.pokemon
 
-	detailsUrl := "https://pokeapi.co/api/v2/location-area/" + areaName + "/"
+	//the pokedex has one entry per species, whatever the form
+	pokemon := speciesName(*pokemonData)
 
-	cachedData, found := cache.Get(detailsUrl)
-	if found {
-		fmt.Println("Using cached data for:", detailsUrl) //optional logging
-		body = cachedData
-	} else {
+	result := &catchResult{Pokemon: pokemon, Ball: ball}
+	s.throws[pokemon]++
 
-		//not in cache make HTTP request
-		fmt.Println("Fetching new data for:", detailsUrl) //optional logging
-		res, err := http.Get(detailsUrl)
-		if err != nil {
-			log.Fatal(err)
+	//trying to catch a pokemon is enough to have seen it
+	entry, seen := s.pokedex[pokemon]
+	if !seen {
+		entry = pokedexEntry{SeenAt: s.clock.Now()}
+	}
+	entry.Species = *pokemonData
+
+	//Determine if you actually caught a pokemon based on its base experience and random chance
+	//Assuming 400 is around the max experience
+	//Note this is a bit crap, but I can't be bothered to optimize your pokemon catching experience
+
+	catchRoll := int(float64(s.rand.Intn(400)+50) * pokeballs[ball])
+	catchLimit := pokemonData.BaseExperience / 2
+
+	if catchRoll >= catchLimit {
+		if !entry.Caught {
+			entry.Caught = true
+			entry.CaughtAt = s.clock.Now()
 		}
 
-		body, err = io.ReadAll(res.Body)
-		defer res.Body.Close()
-		if res.StatusCode > 299 {
-			log.Fatalf("Response failed with status code: %d and\nbody: %s\n", res.StatusCode, body)
+		level := s.areaLevels[pokemonData.Name].roll(s.rand)
+		newPokemon := caughtPokemon{
+			Species:    pokemon,
+			Nickname:   nickname,
+			CaughtAt:   s.clock.Now(),
+			Location:   s.currentArea,
+			Level:      level,
+			Ball:       ball,
+			Throws:     s.throws[pokemon],
+			Nature:     target.nature,
+			IVs:        rollIVs(s.rand),
+			Experience: target.growth.experienceFor(level),
+			GrowthRate: target.growth.Name,
+			Shiny:      rollShiny(s.rand, s.shinyOdds),
+			Gender:     rollGender(s.rand, target.species.GenderRate),
 		}
+		newPokemon.initMoves(*pokemonData, s.gameVersion)
 
-		if err != nil {
-			log.Fatal(err)
+		//catching counts as a win for the lead pokemon, like in the newer games
+		if len(s.storage.Party) > 0 {
+			result.leadID = s.storage.Party[0].ID
+			result.leadExperience = experienceYield(pokemonData.BaseExperience, level)
 		}
-		// Add to cache
-		cache.Add(detailsUrl, body)
+
+		caught, where := s.storage.add(newPokemon)
+		delete(s.throws, pokemon)
+		result.Caught = true
+		result.Individual = &caught
+		result.SentTo = where
 	}
+	s.pokedex[pokemon] = entry
 
-	//dump response into locationDetails struct
-	var locationData *locationDetails = &locationDetails{}
-	if err = json.Unmarshal(body, locationData); err != nil {
-		return err
+	return result
+}
+
+// catchLead returns a copy of the pokemon that gets experience for a catch,
+// and its species, to look up its growth rate with
+func (s *session) catchLead(result *catchResult) (caughtPokemon, pokemonDetails, bool) {
+	if result.leadExperience == 0 {
+		return caughtPokemon{}, pokemonDetails{}, false
 	}
+	lead, ok := s.storage.get(result.leadID)
+	if !ok {
+		return caughtPokemon{}, pokemonDetails{}, false
+	}
+	return *lead, s.pokedex[lead.Species].Species, true
+}
 
-	//Output a list of found Pokemon
-	if len(locationData.PokemonEncounters) == 0 {
-		fmt.Println("No Pokemon found in this area.")
-	} else {
-		fmt.Println("Found Pokemon:")
-		for _, encounter := range locationData.PokemonEncounters {
-			fmt.Printf(" - %s\n", encounter.Pokemon.Name)
-		}
+// finishCatch gives the lead pokemon its experience for a catch, when its
+// growth rate could be found, and saves
+func (s *session) finishCatch(result *catchResult, rate *growthRate) error {
+	if lead, ok := s.storage.get(result.leadID); ok && rate != nil && result.leadExperience > 0 {
+		result.Experience = s.gainExperience(lead, result.leadExperience, rate)
 	}
 
+	if err := s.save(); err != nil {
+		return fmt.Errorf("could not save pokedex: %w", err)
+	}
 	return nil
 }
 
-func commandCatch(cfg *config, cache *pokecache.Cache, args ...string) error {
+func commandCatch(ctx context.Context, s *session, args ...string) error {
 
-	//check if area is provided
-	if len(args) == 0 {
+	names, flags := parseArgs(args)
+
+	//check if pokemon is provided
+	if len(names) == 0 {
 		return fmt.Errorf("missing pokemon name or id")
 	}
 
-	pokemon := args[0]
-	fmt.Printf("Throwing a Pokeball at %s...\n", pokemon)
-
-	//use the cache or API
-	var body []byte
-	var err error
-
-	detailsUrl := "https://pokeapi.co/api/v2/pokemon/" + pokemon + "/"
-
-	cachedData, found := cache.Get(detailsUrl)
-	if found {
-		fmt.Println("Using cached data for:", detailsUrl) //optional logging
-		body = cachedData
-	} else {
+	ball := normalizeName(flags["ball"])
+	if ball == "" {
+		ball = "poke-ball"
+	}
+	if _, ok := pokeballs[ball]; !ok {
+		return fmt.Errorf("unknown ball: %s", flags["ball"])
+	}
 
-		//not in cache make HTTP request
-		fmt.Println("Fetching new data for:", detailsUrl) //optional logging
-		res, err := http.Get(detailsUrl)
-		if err != nil {
-			log.Fatal(err)
-		}
+	target, err := s.fetchCatchTarget(ctx, names)
+	if err != nil {
+		return err
+	}
+	result := s.catchPokemon(target, ball, flags["nickname"])
 
-		body, err = io.ReadAll(res.Body)
-		defer res.Body.Close()
-		if res.StatusCode > 299 {
-			log.Fatalf("Response failed with status code: %d and\nbody: %s\n", res.StatusCode, body)
+	fmt.Fprintf(s.out, "Throwing a %s at %s...\n", ball, result.Pokemon)
+	if result.Caught {
+		if result.Individual.Shiny {
+			fmt.Fprintln(s.out, "Whoa, it's a shiny!")
 		}
+		fmt.Fprintf(s.out, "%s was caught! %s was sent to %s.\n", result.Pokemon, describe(*result.Individual), result.SentTo)
+	} else {
+		fmt.Fprintf(s.out, "%s escaped!\n", result.Pokemon)
+	}
 
-		if err != nil {
-			log.Fatal(err)
+	var rate *growthRate
+	if lead, species, ok := s.catchLead(result); ok {
+		if rate, err = s.growthRateOf(ctx, lead, species); err != nil {
+			fmt.Fprintln(s.out, "could not award experience:", err)
 		}
-		// Add to cache
-		cache.Add(detailsUrl, body)
 	}
-
-	//dump response into locationDetails struct
-	var pokemonData *pokemonDetails = &pokemonDetails{}
-	if err = json.Unmarshal(body, pokemonData); err != nil {
+	if err := s.finishCatch(result, rate); err != nil {
 		return err
 	}
-
-	//Determine if you actually caught a pokemon based on its base experience and random chance
-	//Assuming 400 is around the max experience
-	//Note this is a bit crap, but I can't be bothered to optimize your pokemon catching experience
-
-	catchRoll := rand.Intn(400) + 50
-	catchLimit := pokemonData.BaseExperience / 2
-
-	if catchRoll >= catchLimit {
-		fmt.Printf("%s was caught!\n", pokemon)
-		//add pokemon to pokedex
-		pokedex[pokemon] = *pokemonData
-	} else {
-		fmt.Printf("%s escaped!\n", pokemon)
+	for _, line := range result.Experience {
+		fmt.Fprintln(s.out, line)
 	}
-
 	return nil
 }
 
-func commandInspect(cfg *config, cache *pokecache.Cache, args ...string) error {
+func commandInspect(ctx context.Context, s *session, args ...string) error {
 
-	pokemonName := args[0]
+	names, flags := parseArgs(args, "sprite", "256")
+	if len(names) == 0 {
+		return fmt.Errorf("missing pokemon name or id")
+	}
+	showSprite := flags["sprite"] == "true"
 
-	pokemon, ok := pokedex[pokemonName]
-	if !ok {
-		fmt.Printf("you have not caught that pokemon")
-	} else {
-		fmt.Printf("Name: %s\n", pokemon.Name)
-		fmt.Printf("Height: %d\n", pokemon.Height)
-		fmt.Printf("Weight: %d\n", pokemon.Weight)
-		fmt.Printf("Stats:\n")
-		fmt.Printf("	-hp: %d\n", pokemon.Stats[0].BaseStat)
-		fmt.Printf("	-attack: %d\n", pokemon.Stats[1].BaseStat)
-		fmt.Printf("	-defense: %d\n", pokemon.Stats[2].BaseStat)
-		fmt.Printf("	-special-attack: %d\n", pokemon.Stats[3].BaseStat)
-		fmt.Printf("	-special-defense: %d\n", pokemon.Stats[4].BaseStat)
-		fmt.Printf("	-speed: %d\n", pokemon.Stats[5].BaseStat)
-		fmt.Printf("Types:\n")
-		for _, pType := range pokemon.Types {
-			fmt.Printf("	- %s\n", pType.Type.Name)
+	//#3 means one of your own pokemon, anything else is a species
+	if strings.HasPrefix(names[0], "#") {
+		id, err := parsePokemonID(names[0])
+		if err != nil {
+			return err
+		}
+		owned, ok := s.storage.get(id)
+		if !ok {
+			return fmt.Errorf("you don't have a pokemon #%d", id)
 		}
 
+		fmt.Fprintf(s.out, "Number: #%d\n", owned.ID)
+		if owned.Nickname != "" {
+			fmt.Fprintf(s.out, "Nickname: %s\n", owned.Nickname)
+		}
+		fmt.Fprintf(s.out, "Level: %s\n", s.describeProgress(ctx, *owned))
+		location := owned.Location
+		if location == "" {
+			location = "an unknown location"
+		}
+		fmt.Fprintf(s.out, "Caught: %s at %s\n", owned.CaughtAt.Format("2006-01-02 15:04"), location)
+		fmt.Fprintf(s.out, "Ball: %s after %d throw(s)\n", owned.Ball, owned.Throws)
+		fmt.Fprintf(s.out, "Nature: %s\n", owned.Nature)
+		if owned.Gender != "" {
+			fmt.Fprintf(s.out, "Gender: %s\n", owned.Gender)
+		}
+		if owned.Shiny {
+			fmt.Fprintf(s.out, "Shiny: yes ★\n")
+		}
+		if len(owned.Moves) > 0 {
+			fmt.Fprintf(s.out, "Moves: %s\n", strings.Join(owned.Moves, ", "))
+		}
+
+		species := s.pokedex[owned.Species].Species
+		stats := owned.stats(species)
+		fmt.Fprintf(s.out, "Name: %s\n", species.Name)
+		fmt.Fprintf(s.out, "Sprite: %s\n", owned.sprite(species))
+		fmt.Fprintf(s.out, "Height: %d\n", species.Height)
+		fmt.Fprintf(s.out, "Weight: %d\n", species.Weight)
+		fmt.Fprintf(s.out, "Stats at level %d:\n", owned.Level)
+		for _, name := range statNames {
+			fmt.Fprintf(s.out, "	-%s: %d (base %d, IV %d, EV %d)\n", name, stats[name], baseStat(species, name), owned.IVs[name], owned.EVs[name])
+		}
+		fmt.Fprintf(s.out, "Types:\n")
+		for _, pType := range species.Types {
+			fmt.Fprintf(s.out, "	- %s\n", pType.Type.Name)
+		}
+		if showSprite {
+			return s.renderSprite(ctx, owned.sprite(species), colorMode(s.color), flags)
+		}
+		return nil
 	}
 
-	return nil
-}
+	entry, ok := s.findInPokedex(names...)
+	if !ok || !entry.Caught {
+		fmt.Fprintln(s.out, "you have not caught that pokemon")
+		return nil
+	}
 
-func commandPokedex(cfg *config, cache *pokecache.Cache, args ...string) error {
+	printSpecies(s.out, entry.Species)
 
-	for _, pokemon := range pokedex {
-		fmt.Printf(" - %s\n", pokemon.Name)
+	fmt.Fprintf(s.out, "Yours:\n")
+	for _, owned := range s.storage.all() {
+		if owned.Species == speciesName(entry.Species) {
+			fmt.Fprintf(s.out, "	- %s\n", describe(owned))
+		}
+	}
+
+	if showSprite {
+		return s.renderSprite(ctx, entry.Species.Sprites.FrontDefault, colorMode(s.color), flags)
 	}
 
 	return nil
 }
 
+// printSpecies prints the species data shared by every pokemon of a kind
+func printSpecies(w io.Writer, pokemon pokemonDetails) {
+	fmt.Fprintf(w, "Name: %s\n", pokemon.Name)
+	fmt.Fprintf(w, "Height: %d\n", pokemon.Height)
+	fmt.Fprintf(w, "Weight: %d\n", pokemon.Weight)
+	fmt.Fprintf(w, "Stats:\n")
+	for _, name := range statNames {
+		fmt.Fprintf(w, "	-%s: %d\n", name, baseStat(pokemon, name))
+	}
+	fmt.Fprintf(w, "Types:\n")
+	for _, pType := range pokemon.Types {
+		fmt.Fprintf(w, "	- %s\n", pType.Type.Name)
+	}
+}
+
 type cliCommand struct {
 	name        string
 	description string
-	callback    func(cfg *config, cache *pokecache.Cache, args ...string) error
+	callback    func(ctx context.Context, s *session, args ...string) error
 }
 
 type config struct {
 	previousUrl string
 	nextUrl     string
+	savePath    string
+	currentArea string
+	areaLevels  map[string]levelRange
+	throws      map[string]int
+	shinyOdds   int
+	gameVersion string
+	language    string
+	color       string
+	prefetch    int
+	prefetcher  *pokeapi.Prefetcher
+	assetDir    string
+	configPath  string
+	settings    map[string]settingValue
+	clock       clock.Clock
+	client      *pokeapi.Client
+}
+
+// levelRange is the range of levels a pokemon is encountered at
+type levelRange struct {
+	min int
+	max int
+}
+
+// roll picks a level in the range, pokemon met outside an explored area are level 5
+func (l levelRange) roll(r *rand.Rand) int {
+	if l.max == 0 {
+		return 5
+	}
+	return l.min + r.Intn(l.max-l.min+1)
+}
+
+// pokeballs maps each ball to how much it improves the catch roll
+var pokeballs = map[string]float64{
+	"poke-ball":   1,
+	"great-ball":  1.5,
+	"ultra-ball":  2,
+	"master-ball": 255,
 }
 
 type responseBody struct {
@@ -479,6 +777,16 @@ type locationDetails struct {
 	} `json:"pokemon_encounters"`
 }
 
+// localizedName returns the area's name in language, or its API name
+func (l locationDetails) localizedName(language string) string {
+	for _, name := range l.Names {
+		if name.Language.Name == language && name.Name != "" {
+			return name.Name
+		}
+	}
+	return l.Name
+}
+
 type pokemonDetails struct {
 	Abilities []struct {
 		Ability struct {
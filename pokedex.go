@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// pokedexEntry is what the pokedex knows about a species the player has
+// come across, whether or not they managed to catch one
+type pokedexEntry struct {
+	Species  pokemonDetails `json:"species"`
+	SeenAt   time.Time      `json:"seen_at"`
+	Caught   bool           `json:"caught"`
+	CaughtAt time.Time      `json:"caught_at,omitzero"`
+}
+
+// caughtPokemon is one individual pokemon the player owns, with the details
+// of how it was caught. Its species data lives in the pokedex.
+type caughtPokemon struct {
+	ID         int            `json:"id"`
+	Species    string         `json:"species"`
+	Nickname   string         `json:"nickname,omitempty"`
+	CaughtAt   time.Time      `json:"caught_at"`
+	Location   string         `json:"location,omitempty"`
+	Level      int            `json:"level"`
+	Ball       string         `json:"ball"`
+	Throws     int            `json:"throws"`
+	Nature     nature         `json:"nature"`
+	Experience int            `json:"experience"`
+	GrowthRate string         `json:"growth_rate,omitempty"`
+	Moves      []string       `json:"moves,omitempty"`
+	Shiny      bool           `json:"shiny,omitempty"`
+	Gender     string         `json:"gender,omitempty"`
+	IVs        map[string]int `json:"ivs,omitempty"`
+	EVs        map[string]int `json:"evs,omitempty"`
+}
+
+// displayName is the nickname if there is one, otherwise the species name
+func (c caughtPokemon) displayName() string {
+	if c.Nickname != "" {
+		return c.Nickname
+	}
+	return c.Species
+}
+
+// region is the national dex range introduced by one generation
+type region struct {
+	generation int
+	name       string
+	first      int
+	last       int
+}
+
+var regions = []region{
+	{1, "Kanto", 1, 151},
+	{2, "Johto", 152, 251},
+	{3, "Hoenn", 252, 386},
+	{4, "Sinnoh", 387, 493},
+	{5, "Unova", 494, 649},
+	{6, "Kalos", 650, 721},
+	{7, "Alola", 722, 809},
+	{8, "Galar", 810, 905},
+	{9, "Paldea", 906, 1025},
+}
+
+// nationalDexSize is the number of species in the national dex
+const nationalDexSize = 1025
+
+// speciesID returns the national dex number of a pokemon. Alternate forms
+// have their own pokemon IDs above 10000, so the species URL is used.
+func speciesID(p pokemonDetails) int {
+	parts := strings.Split(strings.TrimSuffix(p.Species.URL, "/"), "/")
+	if id, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+		return id
+	}
+	return p.ID
+}
+
+// speciesName returns the species of a pokemon, which is what the pokedex
+// is keyed by: deoxys-attack and deoxys-speed are both deoxys
+func speciesName(p pokemonDetails) string {
+	if p.Species.Name != "" {
+		return p.Species.Name
+	}
+	return p.Name
+}
+
+// generationOf returns the generation a pokemon was introduced in, or 0
+func generationOf(p pokemonDetails) int {
+	id := speciesID(p)
+	for _, r := range regions {
+		if id >= r.first && id <= r.last {
+			return r.generation
+		}
+	}
+	return 0
+}
+
+// parseGeneration accepts "1", "i", "generation-i" or a region name
+func parseGeneration(s string) (int, error) {
+	s = strings.TrimPrefix(normalizeName(s), "generation-")
+	numerals := []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix"}
+
+	for i, r := range regions {
+		if s == strconv.Itoa(r.generation) || s == numerals[i] || s == strings.ToLower(r.name) {
+			return r.generation, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown generation: %s", s)
+}
+
+// baseStatTotal sums all base stats of a pokemon
+func baseStatTotal(p pokemonDetails) int {
+	total := 0
+	for _, stat := range p.Stats {
+		total += stat.BaseStat
+	}
+	return total
+}
+
+// typeNames returns the pokemon's types in slot order
+func typeNames(p pokemonDetails) []string {
+	var names []string
+	for _, pType := range p.Types {
+		names = append(names, pType.Type.Name)
+	}
+	return names
+}
+
+// sortPokedex orders entries by the given key, falling back to ID for ties
+func sortPokedex(entries []pokedexEntry, key string) error {
+	var less func(a, b pokedexEntry) bool
+
+	switch key {
+	case "", "id":
+		less = func(a, b pokedexEntry) bool { return false }
+	case "name":
+		less = func(a, b pokedexEntry) bool { return speciesName(a.Species) < speciesName(b.Species) }
+	case "type":
+		less = func(a, b pokedexEntry) bool {
+			return strings.Join(typeNames(a.Species), "/") < strings.Join(typeNames(b.Species), "/")
+		}
+	case "bst":
+		//strongest first
+		less = func(a, b pokedexEntry) bool { return baseStatTotal(a.Species) > baseStatTotal(b.Species) }
+	case "caught-at":
+		less = func(a, b pokedexEntry) bool { return a.CaughtAt.Before(b.CaughtAt) }
+	default:
+		return fmt.Errorf("unknown sort key: %s (use id, name, type, bst or caught-at)", key)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if less(entries[i], entries[j]) {
+			return true
+		}
+		if less(entries[j], entries[i]) {
+			return false
+		}
+		return speciesID(entries[i].Species) < speciesID(entries[j].Species)
+	})
+	return nil
+}
+
+// completionSummary reports caught species per region and for the national
+// dex, e.g. "42/151 Kanto, 12% national". Only the given generation is shown
+// when it is not 0, otherwise every region with at least one catch.
+func completionSummary(entries []pokedexEntry, generation int) string {
+	caught := make(map[int]int)
+	species := make(map[int]bool)
+	for _, entry := range entries {
+		id := speciesID(entry.Species)
+		if !entry.Caught || species[id] {
+			continue
+		}
+		species[id] = true
+		caught[generationOf(entry.Species)]++
+	}
+
+	var parts []string
+	for _, r := range regions {
+		if (generation == 0 && caught[r.generation] > 0) || generation == r.generation {
+			parts = append(parts, fmt.Sprintf("%d/%d %s", caught[r.generation], r.last-r.first+1, r.name))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%d%% national", len(species)*100/nationalDexSize))
+
+	return strings.Join(parts, ", ")
+}
+
+// ownsShiny is true when the player has a shiny of the species
+func (s *session) ownsShiny(species string) bool {
+	for _, p := range s.storage.all() {
+		if p.Species == species && p.Shiny {
+			return true
+		}
+	}
+	return false
+}
+
+// pokedexQuery picks which pokedex entries to list and in what order
+type pokedexQuery struct {
+	seen        bool
+	generation  int
+	pokemonType string
+	sort        string
+}
+
+// parsePokedexQuery reads a query from --seen, --generation, --type and
+// --sort flags
+func parsePokedexQuery(flags map[string]string) (pokedexQuery, error) {
+	q := pokedexQuery{
+		seen:        flags["seen"] == "true",
+		pokemonType: cleanInput(flags["type"]),
+		sort:        cleanInput(flags["sort"]),
+	}
+	if flags["generation"] != "" {
+		var err error
+		if q.generation, err = parseGeneration(flags["generation"]); err != nil {
+			return pokedexQuery{}, err
+		}
+	}
+	return q, nil
+}
+
+// listPokedex returns the pokedex entries matching a query, sorted
+func (s *session) listPokedex(q pokedexQuery) ([]pokedexEntry, error) {
+	var entries []pokedexEntry
+	for _, entry := range s.pokedex {
+		if !entry.Caught && !q.seen {
+			continue
+		}
+		if q.generation != 0 && generationOf(entry.Species) != q.generation {
+			continue
+		}
+		if q.pokemonType != "" && !slices.Contains(typeNames(entry.Species), q.pokemonType) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := sortPokedex(entries, q.sort); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func commandPokedex(ctx context.Context, s *session, args ...string) error {
+
+	_, flags := parseArgs(args, "seen")
+	q, err := parsePokedexQuery(flags)
+	if err != nil {
+		return err
+	}
+	entries, err := s.listPokedex(q)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(s.out, "No pokemon in your pokedex match that.")
+	} else {
+		w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tTYPES\tBST\tCAUGHT")
+		for _, entry := range entries {
+			name := speciesName(entry.Species)
+			if s.ownsShiny(name) {
+				name += " ★"
+			}
+			caught := "seen"
+			if entry.Caught {
+				caught = entry.CaughtAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n",
+				speciesID(entry.Species),
+				name,
+				strings.Join(typeNames(entry.Species), "/"),
+				baseStatTotal(entry.Species),
+				caught,
+			)
+		}
+		w.Flush()
+	}
+
+	//completion is always against everything caught, not just the filtered view
+	var all []pokedexEntry
+	for _, entry := range s.pokedex {
+		all = append(all, entry)
+	}
+	fmt.Fprintf(s.out, "Completion: %s\n", completionSummary(all, q.generation))
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func testPokemon(t *testing.T, raw string) pokemonDetails {
+	t.Helper()
+	var p pokemonDetails
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		t.Fatalf("bad test pokemon: %v", err)
+	}
+	return p
+}
+
+func TestSortPokedex(t *testing.T) {
+	now := time.Now()
+	entries := []pokedexEntry{
+		{Species: testPokemon(t, `{"id": 25, "name": "pikachu", "stats": [{"base_stat": 320}]}`), CaughtAt: now},
+		{Species: testPokemon(t, `{"id": 4, "name": "charmander", "stats": [{"base_stat": 309}]}`), CaughtAt: now.Add(time.Hour)},
+		{Species: testPokemon(t, `{"id": 150, "name": "mewtwo", "stats": [{"base_stat": 680}]}`), CaughtAt: now.Add(-time.Hour)},
+	}
+
+	cases := []struct {
+		key      string
+		expected []string
+	}{
+		{key: "id", expected: []string{"charmander", "pikachu", "mewtwo"}},
+		{key: "name", expected: []string{"charmander", "mewtwo", "pikachu"}},
+		{key: "bst", expected: []string{"mewtwo", "pikachu", "charmander"}},
+		{key: "caught-at", expected: []string{"mewtwo", "pikachu", "charmander"}},
+	}
+
+	for _, c := range cases {
+		if err := sortPokedex(entries, c.key); err != nil {
+			t.Fatalf("sort by %s: %v", c.key, err)
+		}
+		for i, name := range c.expected {
+			if entries[i].Species.Name != name {
+				t.Errorf("sort by %s: position %d got '%s', expected '%s'", c.key, i, entries[i].Species.Name, name)
+			}
+		}
+	}
+
+	if err := sortPokedex(entries, "height"); err == nil {
+		t.Errorf("expected error for unknown sort key")
+	}
+}
+
+func TestCompletionSummary(t *testing.T) {
+	entries := []pokedexEntry{
+		{Species: testPokemon(t, `{"id": 25, "species": {"url": "https://pokeapi.co/api/v2/pokemon-species/25/"}}`), Caught: true},
+		{Species: testPokemon(t, `{"id": 10080, "species": {"url": "https://pokeapi.co/api/v2/pokemon-species/25/"}}`), Caught: true},
+		{Species: testPokemon(t, `{"id": 152, "species": {"url": "https://pokeapi.co/api/v2/pokemon-species/152/"}}`), Caught: true},
+		{Species: testPokemon(t, `{"id": 4, "species": {"url": "https://pokeapi.co/api/v2/pokemon-species/4/"}}`)},
+	}
+
+	actual := completionSummary(entries, 0)
+	expected := "1/151 Kanto, 1/100 Johto, 0% national"
+	if actual != expected {
+		t.Errorf("got '%s', expected '%s'", actual, expected)
+	}
+
+	actual = completionSummary(entries, 3)
+	expected = "0/135 Hoenn, 0% national"
+	if actual != expected {
+		t.Errorf("got '%s', expected '%s'", actual, expected)
+	}
+}
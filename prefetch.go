@@ -0,0 +1,31 @@
+package main
+
+// prefetchPage warms the next page of areas and the details of the areas
+// just listed, since one of those is usually asked for next
+func prefetchPage(cfg *config, locations responseBody) {
+	if cfg.prefetcher == nil {
+		return
+	}
+	var urls []string
+	if locations.Next != "" {
+		urls = append(urls, locations.Next)
+	}
+	//explore asks by name, so that is the URL to warm
+	for _, location := range locations.Results {
+		urls = append(urls, cfg.client.URL("location-area/"+location.Name+"/"))
+	}
+	cfg.prefetcher.Warm(cfg.prefetch, urls...)
+}
+
+// prefetchEncounters warms the pokemon of an explored area for catch and
+// battle
+func prefetchEncounters(cfg *config, area locationDetails) {
+	if cfg.prefetcher == nil {
+		return
+	}
+	var urls []string
+	for _, encounter := range area.PokemonEncounters {
+		urls = append(urls, cfg.client.URL("pokemon/"+encounter.Pokemon.Name+"/"))
+	}
+	cfg.prefetcher.Warm(cfg.prefetch, urls...)
+}
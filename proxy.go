@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// commandProxy shares the API cache with other pokedexes until Ctrl-C:
+// proxy [--listen :9000]
+func commandProxy(s *session, args []string) error {
+	proxyFlags := flag.NewFlagSet("proxy", flag.ExitOnError)
+	listen := proxyFlags.String("listen", ":9000", "address to listen on")
+	proxyFlags.Parse(args)
+
+	fmt.Fprintf(s.out, "Proxying %s on %s\n", s.client.BaseURL(), *listen)
+	if err := listenUntilInterrupted(&http.Server{Addr: *listen, Handler: pokeapi.NewProxy(s.client)}); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, "Proxy stopped.")
+	return nil
+}
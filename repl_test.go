@@ -26,3 +26,42 @@ func TestCleanInput(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		input    []string
+		expected string
+	}{
+		{input: []string{"Pikachu"}, expected: "pikachu"},
+		{input: []string{"025"}, expected: "25"},
+		{input: []string{"Mr.", "Mime"}, expected: "mr-mime"},
+		{input: []string{"mr_mime"}, expected: "mr-mime"},
+		{input: []string{"Farfetch'd"}, expected: "farfetchd"},
+		{input: []string{"Nidoran♀"}, expected: "nidoran-f"},
+		{input: []string{"  Ho-Oh "}, expected: "ho-oh"},
+	}
+
+	for _, c := range cases {
+		actual := normalizeName(c.input...)
+		if actual != c.expected {
+			t.Errorf("Mismatch for input '%v': got '%s', expected '%s'", c.input, actual, c.expected)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	positional, flags := parseArgs([]string{"--sort", "Name", "pikachu", "--type=fire", "--shiny", "mew"}, "shiny")
+
+	if len(positional) != 2 || positional[0] != "pikachu" || positional[1] != "mew" {
+		t.Errorf("unexpected positional args: %v", positional)
+	}
+	if flags["sort"] != "Name" {
+		t.Errorf("expected sort flag 'Name', got '%s'", flags["sort"])
+	}
+	if flags["type"] != "fire" {
+		t.Errorf("expected type flag 'fire', got '%s'", flags["type"])
+	}
+	if flags["shiny"] != "true" {
+		t.Errorf("expected shiny flag to be set, got '%s'", flags["shiny"])
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// normalizeName turns user input such as "Mr. Mime", "mr_mime" or "025"
+// into the form the API uses for names and IDs ("mr-mime", "25")
+func normalizeName(args ...string) string {
+	name := cleanInput(strings.Join(args, " "))
+
+	//numeric IDs lose any leading zeros
+	if id, err := strconv.Atoi(name); err == nil {
+		return strconv.Itoa(id)
+	}
+
+	replacer := strings.NewReplacer(
+		"♀", "-f",
+		"♂", "-m",
+		".", "",
+		"'", "",
+		"’", "",
+		":", "",
+		"_", "-",
+		" ", "-",
+	)
+	name = replacer.Replace(name)
+
+	//collapse runs of hyphens left behind by "mr. mime" and friends
+	for strings.Contains(name, "--") {
+		name = strings.ReplaceAll(name, "--", "-")
+	}
+	return strings.Trim(name, "-")
+}
+
+// notFoundError is pokeapi.ErrNotFound worded for the user
+type notFoundError struct {
+	message string
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return target == pokeapi.ErrNotFound
+}
+
+// notFound reports that the API has no such thing, e.g. "unknown pokemon: foo"
+func notFound(format string, args ...any) error {
+	return &notFoundError{fmt.Sprintf(format, args...)}
+}
+
+// resolvePokemon looks up a pokemon by any name or ID the user typed and
+// returns the API data, whose Name and ID are the canonical ones. Species
+// that only exist as forms, like deoxys, resolve to their default form.
+func resolvePokemon(ctx context.Context, client *pokeapi.Client, args ...string) (*pokemonDetails, error) {
+	name := normalizeName(args...)
+	if name == "" {
+		return nil, fmt.Errorf("missing pokemon name or id")
+	}
+
+	body, err := client.Get(ctx, client.URL("pokemon/"+name+"/"))
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		body, err = fetchDefaultVariety(ctx, client, name)
+	}
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		return nil, notFound("unknown pokemon: %s", strings.Join(args, " "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pokemonData *pokemonDetails = &pokemonDetails{}
+	if err = json.Unmarshal(body, pokemonData); err != nil {
+		return nil, err
+	}
+
+	//store under the canonical name too so "25" and "pikachu" share an entry
+	client.Add(client.URL("pokemon/"+pokemonData.Name+"/"), body)
+
+	return pokemonData, nil
+}
+
+// fetchDefaultVariety gets the default form of a species, for names that
+// are species but not pokemon
+func fetchDefaultVariety(ctx context.Context, client *pokeapi.Client, name string) ([]byte, error) {
+	body, err := client.Get(ctx, client.URL("pokemon-species/"+name+"/"))
+	if err != nil {
+		return nil, err
+	}
+	var species speciesDetails
+	if err := json.Unmarshal(body, &species); err != nil {
+		return nil, err
+	}
+	for _, variety := range species.Varieties {
+		if variety.IsDefault {
+			return client.Get(ctx, variety.Pokemon.URL)
+		}
+	}
+	return nil, pokeapi.ErrNotFound
+}
+
+// lookupPokemon finds the species data for either one of your own pokemon,
+// given as "#3", or any species name or ID. owned is nil for species.
+func (s *session) lookupPokemon(ctx context.Context, args ...string) (pokemonDetails, *caughtPokemon, error) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "#") {
+		id, err := parsePokemonID(args[0])
+		if err != nil {
+			return pokemonDetails{}, nil, err
+		}
+		owned, ok := s.storage.get(id)
+		if !ok {
+			return pokemonDetails{}, nil, fmt.Errorf("you don't have a pokemon #%d", id)
+		}
+		return s.pokedex[owned.Species].Species, owned, nil
+	}
+
+	pokemonData, err := resolvePokemon(ctx, s.client, args...)
+	if err != nil {
+		return pokemonDetails{}, nil, err
+	}
+	return *pokemonData, nil, nil
+}
+
+// findInPokedex finds a caught pokemon by name or pokedex ID without
+// touching the network
+func (s *session) findInPokedex(args ...string) (pokedexEntry, bool) {
+	name := normalizeName(args...)
+
+	if entry, ok := s.pokedex[name]; ok {
+		return entry, true
+	}
+
+	//by pokedex ID, or by the name of the form that was met
+	id, err := strconv.Atoi(name)
+	for _, entry := range s.pokedex {
+		if (err == nil && speciesID(entry.Species) == id) || entry.Species.Name == name {
+			return entry, true
+		}
+	}
+
+	return pokedexEntry{}, false
+}
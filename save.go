@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// saveVersion is bumped whenever the save file layout changes
+const saveVersion = 2
+
+type saveFile struct {
+	Version int                     `json:"version"`
+	Pokedex map[string]pokedexEntry `json:"pokedex"`
+	Storage pcStorage               `json:"storage"`
+}
+
+// saveFileV1 is the layout from before pokemon were stored individually,
+// when the pokedex held one caught pokemon per species
+type saveFileV1 struct {
+	Pokedex map[string]struct {
+		Species  pokemonDetails `json:"species"`
+		Nickname string         `json:"nickname,omitempty"`
+		CaughtAt time.Time      `json:"caught_at"`
+		Location string         `json:"location,omitempty"`
+		Level    int            `json:"level"`
+		Ball     string         `json:"ball"`
+		Throws   int            `json:"throws"`
+	} `json:"pokedex"`
+}
+
+// defaultSavePath follows the XDG base directory spec for user data
+func defaultSavePath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "pokedex.json"
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "pokedexcli", "pokedex.json")
+}
+
+// loadPokedex reads the save file at path. A missing file is an empty
+// pokedex and an empty PC.
+func loadPokedex(path string) (map[string]pokedexEntry, pcStorage, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]pokedexEntry), pcStorage{}, nil
+	}
+	if err != nil {
+		return nil, pcStorage{}, err
+	}
+
+	var save saveFile
+	if err := json.Unmarshal(data, &save); err != nil {
+		return nil, pcStorage{}, err
+	}
+
+	switch save.Version {
+	case saveVersion:
+	case 1:
+		if save, err = migrateSaveV1(data); err != nil {
+			return nil, pcStorage{}, err
+		}
+	default:
+		return nil, pcStorage{}, fmt.Errorf("unsupported save file version %d", save.Version)
+	}
+
+	if save.Pokedex == nil {
+		save.Pokedex = make(map[string]pokedexEntry)
+	}
+	return save.Pokedex, save.Storage, nil
+}
+
+// migrateSaveV1 turns each caught pokemon of an old save into a pokedex
+// entry plus an individual in storage, numbered in the order they were caught
+func migrateSaveV1(data []byte) (saveFile, error) {
+	var old saveFileV1
+	if err := json.Unmarshal(data, &old); err != nil {
+		return saveFile{}, err
+	}
+
+	save := saveFile{Version: saveVersion, Pokedex: make(map[string]pokedexEntry)}
+	var caught []caughtPokemon
+	for name, p := range old.Pokedex {
+		save.Pokedex[name] = pokedexEntry{Species: p.Species, SeenAt: p.CaughtAt, Caught: true, CaughtAt: p.CaughtAt}
+		caught = append(caught, caughtPokemon{
+			Species:  name,
+			Nickname: p.Nickname,
+			CaughtAt: p.CaughtAt,
+			Location: p.Location,
+			Level:    p.Level,
+			Ball:     p.Ball,
+			Throws:   p.Throws,
+		})
+	}
+
+	sort.Slice(caught, func(i, j int) bool { return caught[i].CaughtAt.Before(caught[j].CaughtAt) })
+	for _, p := range caught {
+		save.Storage.add(p)
+	}
+	return save, nil
+}
+
+// savePokedex writes the pokedex and PC to path, replacing the old file only
+// once the new one is fully written
+func savePokedex(path string, pokedex map[string]pokedexEntry, storage pcStorage) error {
+	data, err := json.Marshal(saveFile{Version: saveVersion, Pokedex: pokedex, Storage: storage})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
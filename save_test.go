@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadPokedex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pokedexcli", "pokedex.json")
+
+	loaded, loadedStorage, err := loadPokedex(path)
+	if err != nil {
+		t.Fatalf("loading a missing save file: %v", err)
+	}
+	if len(loaded) != 0 || len(loadedStorage.all()) != 0 {
+		t.Fatalf("expected an empty pokedex and PC")
+	}
+
+	caughtAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	saved := map[string]pokedexEntry{
+		"pikachu": {Species: testPokemon(t, `{"id": 25, "name": "pikachu"}`), SeenAt: caughtAt, Caught: true, CaughtAt: caughtAt},
+	}
+	var savedStorage pcStorage
+	savedStorage.add(caughtPokemon{
+		Species:  "pikachu",
+		Nickname: "sparky",
+		CaughtAt: caughtAt,
+		Location: "viridian-forest-area",
+		Level:    4,
+		Ball:     "great-ball",
+		Throws:   3,
+	})
+	if err := savePokedex(path, saved, savedStorage); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	loaded, loadedStorage, err = loadPokedex(path)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if entry, ok := loaded["pikachu"]; !ok || entry.Species.ID != 25 || !entry.Caught {
+		t.Errorf("expected pikachu to be caught in the pokedex, got %+v", entry)
+	}
+	pikachu, ok := loadedStorage.get(1)
+	if !ok {
+		t.Fatalf("expected to find pokemon #1")
+	}
+	if pikachu.Species != "pikachu" || pikachu.Nickname != "sparky" || pikachu.Location != "viridian-forest-area" ||
+		pikachu.Level != 4 || pikachu.Ball != "great-ball" || pikachu.Throws != 3 || !pikachu.CaughtAt.Equal(caughtAt) {
+		t.Errorf("catch details did not survive a save: %+v", pikachu)
+	}
+}
+
+func TestLoadPokedexV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pokedex.json")
+	v1 := `{"version": 1, "pokedex": {
+		"pikachu": {"species": {"id": 25, "name": "pikachu"}, "caught_at": "2025-03-02T12:00:00Z", "level": 4, "ball": "poke-ball", "throws": 1},
+		"eevee": {"species": {"id": 133, "name": "eevee"}, "nickname": "fluffy", "caught_at": "2025-03-01T12:00:00Z", "level": 7, "ball": "poke-ball", "throws": 2}
+	}}`
+	if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, loadedStorage, err := loadPokedex(path)
+	if err != nil {
+		t.Fatalf("loading a version 1 save: %v", err)
+	}
+	if !loaded["pikachu"].Caught || !loaded["eevee"].Caught {
+		t.Errorf("expected both species to be caught, got %+v", loaded)
+	}
+
+	//oldest catch gets the first number
+	eevee, ok := loadedStorage.get(1)
+	if !ok || eevee.Species != "eevee" || eevee.Nickname != "fluffy" {
+		t.Errorf("expected #1 to be eevee, got %+v", eevee)
+	}
+	if len(loadedStorage.Party) != 2 || loadedStorage.NextID != 2 {
+		t.Errorf("expected two pokemon in the party, got %+v", loadedStorage)
+	}
+}
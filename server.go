@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// areasPerPage matches what map shows at a time
+const areasPerPage = 20
+
+// server exposes the pokedex as a JSON API. Requests run concurrently, so
+// the game state is behind a lock.
+type server struct {
+	*session
+	mutex sync.RWMutex
+}
+
+// newServer routes the API endpoints to the same logic the commands use
+func newServer(session *session) http.Handler {
+	s := &server{session: session}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /pokedex", s.handlePokedex)
+	mux.HandleFunc("GET /pokedex/{name}", s.handlePokedexEntry)
+	mux.HandleFunc("POST /catch", s.handleCatch)
+	mux.HandleFunc("GET /areas", s.handleAreas)
+	mux.HandleFunc("GET /areas/{name}", s.handleArea)
+	return mux
+}
+
+func (s *server) handlePokedex(w http.ResponseWriter, r *http.Request) {
+	//the same filters as the pokedex command, as query parameters
+	flags := make(map[string]string)
+	for _, key := range []string{"seen", "generation", "type", "sort"} {
+		flags[key] = r.URL.Query().Get(key)
+	}
+	q, err := parsePokedexQuery(flags)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries, err := s.listPokedex(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if entries == nil {
+		entries = []pokedexEntry{}
+	}
+	var all []pokedexEntry
+	for _, entry := range s.pokedex {
+		all = append(all, entry)
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Entries    []pokedexEntry `json:"entries"`
+		Completion string         `json:"completion"`
+	}{
+		Entries:    entries,
+		Completion: completionSummary(all, q.generation),
+	})
+}
+
+func (s *server) handlePokedexEntry(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, ok := s.findInPokedex(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s is not in your pokedex", r.PathValue("name")))
+		return
+	}
+
+	owned := []caughtPokemon{}
+	for _, p := range s.storage.all() {
+		if p.Species == speciesName(entry.Species) {
+			owned = append(owned, p)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		pokedexEntry
+		Owned []caughtPokemon `json:"owned"`
+	}{entry, owned})
+}
+
+// catchRequest is the body of POST /catch
+type catchRequest struct {
+	Pokemon  string `json:"pokemon"`
+	Ball     string `json:"ball"`
+	Nickname string `json:"nickname"`
+}
+
+func (s *server) handleCatch(w http.ResponseWriter, r *http.Request) {
+	var req catchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad request body: %w", err))
+		return
+	}
+	if req.Pokemon == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing pokemon name or id"))
+		return
+	}
+	ball := normalizeName(req.Ball)
+	if ball == "" {
+		ball = "poke-ball"
+	}
+	if _, ok := pokeballs[ball]; !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown ball: %s", req.Ball))
+		return
+	}
+
+	//the API is only asked while the lock is not held, so reads don't wait on it
+	target, err := s.fetchCatchTarget(r.Context(), []string{req.Pokemon})
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	s.mutex.Lock()
+	result := s.catchPokemon(target, ball, req.Nickname)
+	lead, species, hasLead := s.catchLead(result)
+	s.mutex.Unlock()
+
+	//the catch stands without the experience if the growth rate can't be found
+	var rate *growthRate
+	if hasLead {
+		rate, _ = s.growthRateOf(r.Context(), lead, species)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.finishCatch(result, rate); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *server) handleAreas(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("page must be a number from 1, got %q", value))
+			return
+		}
+	}
+
+	url := s.client.URL(fmt.Sprintf("location-area/?offset=%d&limit=%d", (page-1)*areasPerPage, areasPerPage))
+	locations, err := fetchAreas(r.Context(), s.client, url)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	response := struct {
+		Page     int      `json:"page"`
+		Count    int      `json:"count"`
+		Areas    []string `json:"areas"`
+		NextPage int      `json:"next_page,omitempty"`
+	}{Page: page, Count: locations.Count, Areas: []string{}}
+	for _, location := range locations.Results {
+		response.Areas = append(response.Areas, location.Name)
+	}
+	if locations.Next != "" {
+		response.NextPage = page + 1
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// areaPokemon is a pokemon found in an area and the levels it is met at
+type areaPokemon struct {
+	Name     string `json:"name"`
+	MinLevel int    `json:"min_level"`
+	MaxLevel int    `json:"max_level"`
+}
+
+func (s *server) handleArea(w http.ResponseWriter, r *http.Request) {
+	locationData, err := fetchArea(r.Context(), s.client, normalizeName(r.PathValue("name")))
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	//unlike explore, looking at an area doesn't move the player there
+	levels := locationData.encounterLevels()
+	response := struct {
+		Name        string        `json:"name"`
+		DisplayName string        `json:"display_name"`
+		Pokemon     []areaPokemon `json:"pokemon"`
+	}{
+		Name:        locationData.Name,
+		DisplayName: locationData.localizedName(s.language),
+		Pokemon:     []areaPokemon{},
+	}
+	for _, encounter := range locationData.PokemonEncounters {
+		name := encounter.Pokemon.Name
+		response.Pokemon = append(response.Pokemon, areaPokemon{name, levels[name].min, levels[name].max})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeUpstreamError tells things the API doesn't have apart from the API
+// being unreachable
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusBadGateway, err)
+}
+
+// commandServe runs the JSON API until Ctrl-C: serve [--addr :8080]
+func commandServe(s *session, args []string) error {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveFlags.String("addr", ":8080", "address to listen on")
+	serveFlags.Parse(args)
+
+	fmt.Fprintf(s.out, "Serving the pokedex on %s\n", *addr)
+	if err := listenUntilInterrupted(&http.Server{Addr: *addr, Handler: newServer(s)}); err != nil {
+		return err
+	}
+
+	s.prefetcher.Stop()
+	if err := s.save(); err != nil {
+		return fmt.Errorf("could not save pokedex: %w", err)
+	}
+	fmt.Fprintln(s.out, "Closing the Pokedex... Goodbye!")
+	return nil
+}
+
+// listenUntilInterrupted serves until Ctrl-C, then lets requests in flight
+// finish
+func listenUntilInterrupted(httpServer *http.Server) error {
+	stopped := make(chan error, 1)
+	go func() {
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		<-interrupts
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		stopped <- httpServer.Shutdown(ctx)
+	}()
+
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-stopped
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapitest"
+)
+
+// testServer serves a fresh pokedex backed by the fake API
+func testServer(t *testing.T) (*httptest.Server, *session, *pokeapitest.Server) {
+	t.Helper()
+	session, _, api := testSession(t, nil)
+	s := httptest.NewServer(newServer(session))
+	t.Cleanup(s.Close)
+	return s, session, api
+}
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestServerCatch(t *testing.T) {
+	s, c, _ := testServer(t)
+
+	res, err := http.Post(s.URL+"/catch", "application/json", strings.NewReader(`{"pokemon": "Pikachu", "ball": "master-ball", "nickname": "sparky"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result catchResult
+	json.NewDecoder(res.Body).Decode(&result)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !result.Caught || result.Individual.Nickname != "sparky" {
+		t.Fatalf("expected a master ball to catch, got %d %+v", res.StatusCode, result)
+	}
+
+	var list struct {
+		Entries []pokedexEntry `json:"entries"`
+	}
+	if status := getJSON(t, s.URL+"/pokedex?type=electric", &list); status != http.StatusOK || len(list.Entries) != 1 {
+		t.Errorf("expected pikachu in the pokedex, got %d %+v", status, list)
+	}
+
+	var entry struct {
+		Caught bool            `json:"caught"`
+		Owned  []caughtPokemon `json:"owned"`
+	}
+	if status := getJSON(t, s.URL+"/pokedex/25", &entry); status != http.StatusOK || !entry.Caught || len(entry.Owned) != 1 {
+		t.Errorf("expected the caught pikachu, got %d %+v", status, entry)
+	}
+
+	saved, _, err := loadPokedex(c.savePath)
+	if err != nil || !saved["pikachu"].Caught {
+		t.Errorf("expected the catch to be saved, got %v %v", saved, err)
+	}
+
+	//the lead pokemon's experience is part of the response, not the server's output
+	res, err = http.Post(s.URL+"/catch", "application/json", strings.NewReader(`{"pokemon": "caterpie", "ball": "master-ball"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = catchResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	res.Body.Close()
+	if len(result.Experience) == 0 || !strings.HasPrefix(result.Experience[0], "sparky gained ") {
+		t.Errorf("expected sparky to gain experience, got %d %+v", res.StatusCode, result)
+	}
+}
+
+func TestServerAreas(t *testing.T) {
+	s, c, _ := testServer(t)
+
+	var page struct {
+		Page     int      `json:"page"`
+		Areas    []string `json:"areas"`
+		NextPage int      `json:"next_page"`
+	}
+	if status := getJSON(t, s.URL+"/areas", &page); status != http.StatusOK || page.NextPage != 2 || page.Areas[0] != "viridian-forest-area" {
+		t.Errorf("unexpected first page: %d %+v", status, page)
+	}
+	page.NextPage = 0
+	if status := getJSON(t, s.URL+"/areas?page=2", &page); status != http.StatusOK || page.NextPage != 0 || page.Areas[0] != "route-1-area" {
+		t.Errorf("unexpected last page: %d %+v", status, page)
+	}
+
+	var area struct {
+		DisplayName string        `json:"display_name"`
+		Pokemon     []areaPokemon `json:"pokemon"`
+	}
+	status := getJSON(t, s.URL+"/areas/viridian-forest-area", &area)
+	if status != http.StatusOK || area.DisplayName != "Viridian Forest" || area.Pokemon[1] != (areaPokemon{"pikachu", 3, 5}) {
+		t.Errorf("unexpected area: %d %+v", status, area)
+	}
+	if c.currentArea != "" {
+		t.Errorf("expected looking at an area not to move the player, now in %s", c.currentArea)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s, _, api := testServer(t)
+	api.Fail("/api/v2/location-area/pewter-city-area/", http.StatusServiceUnavailable)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/pokedex/pikachu", "", http.StatusNotFound},
+		{http.MethodGet, "/pokedex?generation=42", "", http.StatusBadRequest},
+		{http.MethodGet, "/areas?page=0", "", http.StatusBadRequest},
+		{http.MethodGet, "/areas/nowhere", "", http.StatusNotFound},
+		{http.MethodGet, "/areas/pewter-city-area", "", http.StatusBadGateway},
+		{http.MethodPost, "/catch", `{"pokemon": "missingno", "ball": "master-ball"}`, http.StatusNotFound},
+		{http.MethodPost, "/catch", `{"pokemon": "pikachu", "ball": "net-ball"}`, http.StatusBadRequest},
+		{http.MethodPost, "/catch", `pikachu`, http.StatusBadRequest},
+		{http.MethodGet, "/catch", "", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, s.URL+c.path, strings.NewReader(c.body))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if res.StatusCode != c.status {
+			t.Errorf("%s %s: got %d (%s), expected %d", c.method, c.path, res.StatusCode, body.Error, c.status)
+		}
+		if c.status != http.StatusMethodNotAllowed && body.Error == "" {
+			t.Errorf("%s %s: expected an error message", c.method, c.path)
+		}
+	}
+}
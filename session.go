@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/assets"
+	"github.com/placki-w/pokedexcli/internal/pokecache"
+)
+
+// errExit is returned by the exit command to end the session
+var errExit = errors.New("exit")
+
+// session is one player's game: the settings, the pokedex and PC, and where
+// commands read their input and write their output. Every command gets it.
+type session struct {
+	*config
+
+	in       io.Reader
+	out      io.Writer
+	commands map[string]cliCommand
+	cache    *pokecache.Cache
+	pokedex  map[string]pokedexEntry
+	storage  pcStorage
+	assets   *assets.Store
+	//rand decides catches, battles and everything else left to chance
+	rand *rand.Rand
+}
+
+// newSession starts a game with the commands of the prompt
+func newSession(cfg *config, cache *pokecache.Cache, in io.Reader, out io.Writer) *session {
+	out = &lockedWriter{w: out}
+	//requests are logged wherever the commands print
+	if cfg.client != nil {
+		cfg.client.Log = out
+	}
+	return &session{
+		config:   cfg,
+		in:       in,
+		out:      out,
+		commands: commandRegistry(),
+		cache:    cache,
+		pokedex:  make(map[string]pokedexEntry),
+		rand:     newRand(rand.Int63()),
+	}
+}
+
+// lockedWriter lets a command that Ctrl-C gave up on keep printing while
+// the prompt says goodbye
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.w.Write(p)
+}
+
+// lockedSource lets one *rand.Rand be shared by the server's requests,
+// which roll natures before they take the server's lock
+type lockedSource struct {
+	mutex  sync.Mutex
+	source rand.Source64
+}
+
+func (l *lockedSource) Int63() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.source.Int63()
+}
+
+func (l *lockedSource) Uint64() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.source.Uint64()
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.source.Seed(seed)
+}
+
+// newRand returns a random source that is safe for concurrent use
+func newRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{source: rand.NewSource(seed).(rand.Source64)})
+}
+
+// save writes the pokedex and PC to the save file
+func (s *session) save() error {
+	return savePokedex(s.savePath, s.pokedex, s.storage)
+}
+
+// commandNames lists the commands alphabetically
+func (s *session) commandNames() []string {
+	names := make([]string, 0, len(s.commands))
+	for name := range s.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run is the prompt: it reads commands until exit, Ctrl-D or a second Ctrl-C
+func (s *session) run(interrupts <-chan os.Signal) {
+	// Lines are read in the background so Ctrl-C can be noticed at the prompt
+	//done lets the reader go once the prompt has ended without reading it all
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(s.in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	interrupted := false
+	for {
+		fmt.Fprint(s.out, "Pokedex > ")
+
+		var input string
+		select {
+		case line, ok := <-lines:
+			//Ctrl-D on an empty line
+			if !ok {
+				fmt.Fprintln(s.out)
+				commandExit(context.Background(), s)
+				return
+			}
+			input = line
+			interrupted = false
+		case <-interrupts:
+			if interrupted {
+				fmt.Fprintln(s.out)
+				commandExit(context.Background(), s)
+				return
+			}
+			interrupted = true
+			fmt.Fprintln(s.out, "\n(press Ctrl-C again or Ctrl-D to exit)")
+			continue
+		}
+
+		// Split the input by spaces to separate command and arguments
+		args := strings.Fields(input)
+		if len(args) == 0 {
+			fmt.Fprintln(s.out, "Please input a command.")
+			continue
+		}
+
+		commandName := strings.ToLower(args[0])
+
+		// Check if the command exists
+		cmd, ok := s.commands[commandName]
+		if !ok {
+			fmt.Fprintln(s.out, "Unknown command")
+			continue
+		}
+
+		// Call the command with any arguments after the command name
+		err := s.runCommand(cmd, args[1:], interrupts)
+		if errors.Is(err, errExit) {
+			return
+		} else if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(s.out, "Cancelled.")
+		} else if err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+	}
+}
+
+// stopTimeout is how long a second Ctrl-C waits for the command to stop
+// before exiting without saving
+const stopTimeout = 5 * time.Second
+
+// runCommand calls a command with a context that Ctrl-C cancels. Pressing
+// Ctrl-C again before the command has stopped exits the pokedex.
+func (s *session) runCommand(cmd cliCommand, args []string, interrupts <-chan os.Signal) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.callback(ctx, s, args...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-interrupts:
+		cancel()
+	}
+
+	select {
+	case err := <-done:
+		//it may have finished before noticing, so it gets the last word
+		return err
+	case <-interrupts:
+	}
+
+	//saving while the command still changes the pokedex could save half of it
+	select {
+	case <-done:
+		fmt.Fprintln(s.out)
+		return commandExit(context.Background(), s)
+	case <-s.clock.After(stopTimeout):
+		fmt.Fprintf(s.out, "\n%s did not stop, exiting without saving.\n", cmd.name)
+		return errExit
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/placki-w/pokedexcli/internal/clocktest"
+)
+
+// runInBackground starts the prompt and returns a channel closed when it ends
+func runInBackground(s *session, interrupts <-chan os.Signal) <-chan struct{} {
+	finished := make(chan struct{})
+	go func() {
+		s.run(interrupts)
+		close(finished)
+	}()
+	return finished
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// addStubbornCommand adds a "slow" command that ignores Ctrl-C until
+// proceed is closed
+func addStubbornCommand(t *testing.T, s *session) (started, proceed chan struct{}) {
+	started, proceed = make(chan struct{}), make(chan struct{})
+	s.commands["slow"] = cliCommand{
+		name: "slow",
+		callback: func(ctx context.Context, s *session, args ...string) error {
+			close(started)
+			<-proceed
+			fmt.Fprintln(s.out, "Finished anyway.")
+			return nil
+		},
+	}
+	t.Cleanup(func() {
+		select {
+		case <-proceed:
+		default:
+			close(proceed)
+		}
+	})
+	return started, proceed
+}
+
+func TestInterruptOnce(t *testing.T) {
+	t.Run("cancels a slow request", func(t *testing.T) {
+		s, out, api := testSession(t, strings.NewReader("catch pikachu --ball master-ball\nparty\n"))
+		arrived, release := api.Hold("/api/v2/pokemon/pikachu/")
+		defer release()
+
+		interrupts := make(chan os.Signal)
+		finished := runInBackground(s, interrupts)
+		waitFor(t, arrived, "the request")
+		interrupts <- os.Interrupt
+		waitFor(t, finished, "the prompt to end")
+
+		for _, want := range []string{"Cancelled.\n", "Your party is empty", "Goodbye!"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected %q in the output:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("keeps what a finished command said", func(t *testing.T) {
+		s, out, _ := testSession(t, strings.NewReader("slow\n"))
+		started, proceed := addStubbornCommand(t, s)
+
+		interrupts := make(chan os.Signal)
+		finished := runInBackground(s, interrupts)
+		waitFor(t, started, "the command")
+		interrupts <- os.Interrupt
+		close(proceed)
+		waitFor(t, finished, "the prompt to end")
+
+		if !strings.Contains(out.String(), "Finished anyway.") || strings.Contains(out.String(), "Cancelled.") {
+			t.Errorf("expected the command to finish normally:\n%s", out)
+		}
+	})
+}
+
+func TestInterruptTwice(t *testing.T) {
+	t.Run("saves once the command stops", func(t *testing.T) {
+		s, out, _ := testSession(t, strings.NewReader("slow\nparty\n"))
+		started, proceed := addStubbornCommand(t, s)
+
+		interrupts := make(chan os.Signal)
+		finished := runInBackground(s, interrupts)
+		waitFor(t, started, "the command")
+		interrupts <- os.Interrupt
+		interrupts <- os.Interrupt
+		close(proceed)
+		waitFor(t, finished, "the prompt to end")
+
+		if !strings.Contains(out.String(), "Goodbye!") || strings.Contains(out.String(), "Your party") {
+			t.Errorf("expected the pokedex to exit:\n%s", out)
+		}
+		if _, err := os.Stat(s.savePath); err != nil {
+			t.Errorf("expected the pokedex to be saved: %v", err)
+		}
+	})
+
+	t.Run("exits without saving a stuck command", func(t *testing.T) {
+		s, out, _ := testSession(t, strings.NewReader("slow\n"))
+		started, _ := addStubbornCommand(t, s)
+		fake := s.clock.(*clocktest.Fake)
+
+		interrupts := make(chan os.Signal)
+		finished := runInBackground(s, interrupts)
+		waitFor(t, started, "the command")
+		waiters := fake.Waiters()
+		interrupts <- os.Interrupt
+		interrupts <- os.Interrupt
+		for fake.Waiters() == waiters {
+			time.Sleep(time.Millisecond)
+		}
+		fake.Advance(stopTimeout)
+		waitFor(t, finished, "the prompt to end")
+
+		if !strings.Contains(out.String(), "slow did not stop, exiting without saving.") {
+			t.Errorf("expected to give up on the command:\n%s", out)
+		}
+		if _, err := os.Stat(s.savePath); err == nil {
+			t.Errorf("expected nothing to be saved")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/placki-w/pokedexcli/internal/termimg"
+)
+
+// spriteSet holds the sprites of one game version
+type spriteSet struct {
+	front      string
+	back       string
+	frontShiny string
+	backShiny  string
+}
+
+// spriteVersions lists the PNG sprites of each game version by the names
+// accepted by "sprite --version"
+func spriteVersions(p pokemonDetails) map[string]spriteSet {
+	v := p.Sprites.Versions
+	return map[string]spriteSet{
+		"gen-i-red-blue":                 {v.GenerationI.RedBlue.FrontDefault, v.GenerationI.RedBlue.BackDefault, "", ""},
+		"gen-i-yellow":                   {v.GenerationI.Yellow.FrontDefault, v.GenerationI.Yellow.BackDefault, "", ""},
+		"gen-ii-crystal":                 {v.GenerationIi.Crystal.FrontDefault, v.GenerationIi.Crystal.BackDefault, v.GenerationIi.Crystal.FrontShiny, v.GenerationIi.Crystal.BackShiny},
+		"gen-ii-gold":                    {v.GenerationIi.Gold.FrontDefault, v.GenerationIi.Gold.BackDefault, v.GenerationIi.Gold.FrontShiny, v.GenerationIi.Gold.BackShiny},
+		"gen-ii-silver":                  {v.GenerationIi.Silver.FrontDefault, v.GenerationIi.Silver.BackDefault, v.GenerationIi.Silver.FrontShiny, v.GenerationIi.Silver.BackShiny},
+		"gen-iii-emerald":                {v.GenerationIii.Emerald.FrontDefault, "", v.GenerationIii.Emerald.FrontShiny, ""},
+		"gen-iii-firered-leafgreen":      {v.GenerationIii.FireredLeafgreen.FrontDefault, v.GenerationIii.FireredLeafgreen.BackDefault, v.GenerationIii.FireredLeafgreen.FrontShiny, v.GenerationIii.FireredLeafgreen.BackShiny},
+		"gen-iii-ruby-sapphire":          {v.GenerationIii.RubySapphire.FrontDefault, v.GenerationIii.RubySapphire.BackDefault, v.GenerationIii.RubySapphire.FrontShiny, v.GenerationIii.RubySapphire.BackShiny},
+		"gen-iv-diamond-pearl":           {v.GenerationIv.DiamondPearl.FrontDefault, v.GenerationIv.DiamondPearl.BackDefault, v.GenerationIv.DiamondPearl.FrontShiny, v.GenerationIv.DiamondPearl.BackShiny},
+		"gen-iv-heartgold-soulsilver":    {v.GenerationIv.HeartgoldSoulsilver.FrontDefault, v.GenerationIv.HeartgoldSoulsilver.BackDefault, v.GenerationIv.HeartgoldSoulsilver.FrontShiny, v.GenerationIv.HeartgoldSoulsilver.BackShiny},
+		"gen-iv-platinum":                {v.GenerationIv.Platinum.FrontDefault, v.GenerationIv.Platinum.BackDefault, v.GenerationIv.Platinum.FrontShiny, v.GenerationIv.Platinum.BackShiny},
+		"gen-v-black-white":              {v.GenerationV.BlackWhite.FrontDefault, v.GenerationV.BlackWhite.BackDefault, v.GenerationV.BlackWhite.FrontShiny, v.GenerationV.BlackWhite.BackShiny},
+		"gen-vi-x-y":                     {v.GenerationVi.XY.FrontDefault, "", v.GenerationVi.XY.FrontShiny, ""},
+		"gen-vi-omegaruby-alphasapphire": {v.GenerationVi.OmegarubyAlphasapphire.FrontDefault, "", v.GenerationVi.OmegarubyAlphasapphire.FrontShiny, ""},
+		"gen-vii-ultra-sun-ultra-moon":   {v.GenerationVii.UltraSunUltraMoon.FrontDefault, "", v.GenerationVii.UltraSunUltraMoon.FrontShiny, ""},
+		"home":                           {p.Sprites.Other.Home.FrontDefault, "", p.Sprites.Other.Home.FrontShiny, ""},
+		"official-artwork":               {p.Sprites.Other.OfficialArtwork.FrontDefault, "", p.Sprites.Other.OfficialArtwork.FrontShiny, ""},
+	}
+}
+
+// versionSpriteURL picks a sprite from one game version
+func versionSpriteURL(p pokemonDetails, version string, shiny, back bool) (string, error) {
+	versions := spriteVersions(p)
+	set, ok := versions[version]
+	if !ok {
+		var names []string
+		for name := range versions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown sprite version %s, try one of: %s", version, strings.Join(names, ", "))
+	}
+
+	url := set.front
+	switch {
+	case back && shiny:
+		url = set.backShiny
+	case back:
+		url = set.back
+	case shiny:
+		url = set.frontShiny
+	}
+	if url == "" {
+		return "", fmt.Errorf("%s has no such sprite in %s", p.Name, version)
+	}
+	return url, nil
+}
+
+// renderSprite downloads a PNG sprite through the cache and draws it
+func (s *session) renderSprite(ctx context.Context, url string, mode termimg.Mode, flags map[string]string) error {
+	if url == "" {
+		return fmt.Errorf("there is no sprite for that")
+	}
+
+	opts := termimg.Options{Mode: mode}
+	if flags["256"] == "true" {
+		opts.Mode = termimg.Color256
+	}
+	if flags["width"] != "" {
+		width, err := strconv.Atoi(flags["width"])
+		if err != nil || width < 1 {
+			return fmt.Errorf("expected a width in columns, got %s", flags["width"])
+		}
+		opts.Width = width
+	}
+
+	body, err := s.fetchAsset(ctx, url)
+	if err != nil {
+		return err
+	}
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not decode sprite %s: %w", url, err)
+	}
+	return termimg.Render(s.out, img, opts)
+}
+
+func commandSprite(ctx context.Context, s *session, args ...string) error {
+	names, flags := parseArgs(args, "shiny", "back", "female", "256")
+	if len(names) == 0 {
+		return fmt.Errorf("usage: sprite <pokemon|#id> [--shiny] [--back] [--female] [--version <version>] [--width <n>] [--256]")
+	}
+
+	shiny := flags["shiny"] == "true"
+	female := flags["female"] == "true"
+	back := flags["back"] == "true"
+
+	pokemonData, owned, err := s.lookupPokemon(ctx, names...)
+	if err != nil {
+		return err
+	}
+	//your own pokemon look the way they are unless asked otherwise
+	if owned != nil {
+		shiny = shiny || owned.Shiny
+		female = female || owned.Gender == genderFemale
+	}
+
+	url := spriteURL(pokemonData, shiny, female, back)
+	if flags["version"] != "" {
+		if url, err = versionSpriteURL(pokemonData, cleanInput(flags["version"]), shiny, back); err != nil {
+			return err
+		}
+	}
+
+	return s.renderSprite(ctx, url, colorMode(s.color), flags)
+}
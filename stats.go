@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// statNames lists the six stats in the order the games show them
+var statNames = []string{"hp", "attack", "defense", "special-attack", "special-defense", "speed"}
+
+const (
+	maxIV       = 31
+	maxStatEVs  = 252
+	maxTotalEVs = 510
+)
+
+// nature raises one stat by 10% and lowers another by 10%. Neutral natures
+// raise and lower the same stat, which cancels out.
+type nature struct {
+	Name      string `json:"name"`
+	Increased string `json:"increased,omitempty"`
+	Decreased string `json:"decreased,omitempty"`
+}
+
+func (n nature) String() string {
+	if n.Name == "" {
+		return "unknown"
+	}
+	if n.Increased == "" || n.Increased == n.Decreased {
+		return n.Name
+	}
+	return fmt.Sprintf("%s (+%s -%s)", n.Name, n.Increased, n.Decreased)
+}
+
+// modifier returns the nature's effect on a stat: 1.1, 0.9 or 1
+func (n nature) modifier(stat string) float64 {
+	switch {
+	case n.Increased == n.Decreased:
+		return 1
+	case stat == n.Increased:
+		return 1.1
+	case stat == n.Decreased:
+		return 0.9
+	}
+	return 1
+}
+
+type natureList struct {
+	Results []struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"results"`
+}
+
+type natureDetails struct {
+	Name          string `json:"name"`
+	IncreasedStat *struct {
+		Name string `json:"name"`
+	} `json:"increased_stat"`
+	DecreasedStat *struct {
+		Name string `json:"name"`
+	} `json:"decreased_stat"`
+}
+
+// rollNature picks one of the natures from the API at random
+func rollNature(ctx context.Context, client *pokeapi.Client, r *rand.Rand) (nature, error) {
+	body, err := client.Get(ctx, client.URL("nature/?limit=100"))
+	if err != nil {
+		return nature{}, err
+	}
+	var list natureList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nature{}, err
+	}
+	if len(list.Results) == 0 {
+		return nature{}, fmt.Errorf("the API returned no natures")
+	}
+
+	body, err = client.Get(ctx, list.Results[r.Intn(len(list.Results))].URL)
+	if err != nil {
+		return nature{}, err
+	}
+	var details natureDetails
+	if err := json.Unmarshal(body, &details); err != nil {
+		return nature{}, err
+	}
+
+	n := nature{Name: details.Name}
+	if details.IncreasedStat != nil && details.DecreasedStat != nil {
+		n.Increased = details.IncreasedStat.Name
+		n.Decreased = details.DecreasedStat.Name
+	}
+	return n, nil
+}
+
+// rollIVs gives every stat a random individual value from 0 to 31
+func rollIVs(r *rand.Rand) map[string]int {
+	ivs := make(map[string]int)
+	for _, stat := range statNames {
+		ivs[stat] = r.Intn(maxIV + 1)
+	}
+	return ivs
+}
+
+// baseStat returns a species' base value for the named stat
+func baseStat(p pokemonDetails, name string) int {
+	for _, stat := range p.Stats {
+		if stat.Stat.Name == name {
+			return stat.BaseStat
+		}
+	}
+	return 0
+}
+
+// effortYield returns the EVs awarded for defeating a species
+func effortYield(p pokemonDetails) map[string]int {
+	evs := make(map[string]int)
+	for _, stat := range p.Stats {
+		if stat.Effort > 0 {
+			evs[stat.Stat.Name] = stat.Effort
+		}
+	}
+	return evs
+}
+
+// computeStat applies the in-game stat formula from generation III onwards
+func computeStat(name string, base, iv, ev, level int, n nature) int {
+	value := (2*base + iv + ev/4) * level / 100
+	if name == "hp" {
+		return value + level + 10
+	}
+	return int(float64(value+5) * n.modifier(name))
+}
+
+// stats returns the pokemon's actual stats at its current level
+func (c caughtPokemon) stats(species pokemonDetails) map[string]int {
+	stats := make(map[string]int)
+	for _, name := range statNames {
+		stats[name] = computeStat(name, baseStat(species, name), c.IVs[name], c.EVs[name], c.Level, c.Nature)
+	}
+	return stats
+}
+
+// gainEVs adds effort values, respecting the per-stat and total caps, and
+// returns what was actually gained
+func (c *caughtPokemon) gainEVs(evs map[string]int) map[string]int {
+	if c.EVs == nil {
+		c.EVs = make(map[string]int)
+	}
+	total := 0
+	for _, ev := range c.EVs {
+		total += ev
+	}
+
+	gained := make(map[string]int)
+	for _, stat := range statNames {
+		gain := min(evs[stat], maxStatEVs-c.EVs[stat], maxTotalEVs-total)
+		if gain <= 0 {
+			continue
+		}
+		c.EVs[stat] += gain
+		total += gain
+		gained[stat] = gain
+	}
+	return gained
+}
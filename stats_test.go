@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestComputeStat(t *testing.T) {
+	// Garchomp from Bulbapedia's worked example: level 78, adamant
+	adamant := nature{Name: "adamant", Increased: "attack", Decreased: "special-attack"}
+	cases := []struct {
+		stat     string
+		base     int
+		iv       int
+		ev       int
+		expected int
+	}{
+		{stat: "hp", base: 108, iv: 24, ev: 74, expected: 289},
+		{stat: "attack", base: 130, iv: 12, ev: 190, expected: 278},
+		{stat: "defense", base: 95, iv: 30, ev: 91, expected: 193},
+		{stat: "special-attack", base: 80, iv: 16, ev: 48, expected: 135},
+		{stat: "special-defense", base: 85, iv: 23, ev: 84, expected: 171},
+		{stat: "speed", base: 102, iv: 5, ev: 23, expected: 171},
+	}
+
+	for _, c := range cases {
+		actual := computeStat(c.stat, c.base, c.iv, c.ev, 78, adamant)
+		if actual != c.expected {
+			t.Errorf("%s: got %d, expected %d", c.stat, actual, c.expected)
+		}
+	}
+}
+
+func TestGainEVs(t *testing.T) {
+	p := caughtPokemon{EVs: map[string]int{"attack": 250, "speed": 200}}
+
+	gained := p.gainEVs(map[string]int{"attack": 3, "hp": 2})
+	if gained["attack"] != 2 || p.EVs["attack"] != maxStatEVs {
+		t.Errorf("expected attack to stop at %d, got %d", maxStatEVs, p.EVs["attack"])
+	}
+	if gained["hp"] != 2 {
+		t.Errorf("expected 2 hp EVs, got %d", gained["hp"])
+	}
+
+	p.EVs["defense"] = 56
+	gained = p.gainEVs(map[string]int{"defense": 3})
+	if gained["defense"] != 0 {
+		t.Errorf("expected no EVs past the total cap of %d, got %d", maxTotalEVs, gained["defense"])
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	partySize = 6
+	boxSize   = 30
+)
+
+// pcStorage holds every pokemon the player owns: up to six in the party
+// and the rest in numbered PC boxes
+type pcStorage struct {
+	Party  []caughtPokemon   `json:"party"`
+	Boxes  [][]caughtPokemon `json:"boxes"`
+	NextID int               `json:"next_id"`
+}
+
+// add gives a newly caught pokemon its ID and puts it in the party, or the
+// first box with room. It returns where the pokemon went.
+func (s *pcStorage) add(p caughtPokemon) (caughtPokemon, string) {
+	s.NextID++
+	p.ID = s.NextID
+
+	if len(s.Party) < partySize {
+		s.Party = append(s.Party, p)
+		return p, "your party"
+	}
+
+	for i := range s.Boxes {
+		if len(s.Boxes[i]) < boxSize {
+			s.Boxes[i] = append(s.Boxes[i], p)
+			return p, fmt.Sprintf("PC box %d", i+1)
+		}
+	}
+
+	s.Boxes = append(s.Boxes, []caughtPokemon{p})
+	return p, fmt.Sprintf("PC box %d", len(s.Boxes))
+}
+
+// find returns the slice holding the pokemon with the given ID, its index in
+// that slice and its box number (0 for the party)
+func (s *pcStorage) find(id int) (*[]caughtPokemon, int, int) {
+	for i, p := range s.Party {
+		if p.ID == id {
+			return &s.Party, i, 0
+		}
+	}
+	for b := range s.Boxes {
+		for i, p := range s.Boxes[b] {
+			if p.ID == id {
+				return &s.Boxes[b], i, b + 1
+			}
+		}
+	}
+	return nil, -1, -1
+}
+
+// get returns the pokemon with the given ID
+func (s *pcStorage) get(id int) (*caughtPokemon, bool) {
+	slot, i, _ := s.find(id)
+	if slot == nil {
+		return nil, false
+	}
+	return &(*slot)[i], true
+}
+
+// all returns every owned pokemon, party first
+func (s *pcStorage) all() []caughtPokemon {
+	all := slices.Clone(s.Party)
+	for _, box := range s.Boxes {
+		all = append(all, box...)
+	}
+	return all
+}
+
+// deposit moves a party pokemon into a box, the first with room when box is 0
+func (s *pcStorage) deposit(id int, box int) error {
+	slot, i, from := s.find(id)
+	if slot == nil {
+		return fmt.Errorf("you don't have a pokemon #%d", id)
+	}
+	if from != 0 {
+		return fmt.Errorf("#%d is already in box %d", id, from)
+	}
+	if len(s.Party) == 1 {
+		return fmt.Errorf("you can't deposit your last party pokemon")
+	}
+
+	if box == 0 {
+		for box = 1; box <= len(s.Boxes); box++ {
+			if len(s.Boxes[box-1]) < boxSize {
+				break
+			}
+		}
+	}
+	if box < 1 || box > len(s.Boxes)+1 {
+		return fmt.Errorf("there is no box %d", box)
+	}
+	if box == len(s.Boxes)+1 {
+		s.Boxes = append(s.Boxes, nil)
+	}
+	if len(s.Boxes[box-1]) >= boxSize {
+		return fmt.Errorf("box %d is full", box)
+	}
+
+	p := s.Party[i]
+	s.Party = slices.Delete(s.Party, i, i+1)
+	s.Boxes[box-1] = append(s.Boxes[box-1], p)
+	return nil
+}
+
+// withdraw moves a boxed pokemon into the party
+func (s *pcStorage) withdraw(id int) error {
+	slot, i, from := s.find(id)
+	if slot == nil {
+		return fmt.Errorf("you don't have a pokemon #%d", id)
+	}
+	if from == 0 {
+		return fmt.Errorf("#%d is already in your party", id)
+	}
+	if len(s.Party) >= partySize {
+		return fmt.Errorf("your party is full, deposit or swap a pokemon first")
+	}
+
+	p := (*slot)[i]
+	*slot = slices.Delete(*slot, i, i+1)
+	s.Party = append(s.Party, p)
+	return nil
+}
+
+// release removes a pokemon for good
+func (s *pcStorage) release(id int) (caughtPokemon, error) {
+	slot, i, _ := s.find(id)
+	if slot == nil {
+		return caughtPokemon{}, fmt.Errorf("you don't have a pokemon #%d", id)
+	}
+
+	p := (*slot)[i]
+	*slot = slices.Delete(*slot, i, i+1)
+	return p, nil
+}
+
+// swap exchanges the places of two pokemon, wherever they are
+func (s *pcStorage) swap(a, b int) error {
+	slotA, i, _ := s.find(a)
+	if slotA == nil {
+		return fmt.Errorf("you don't have a pokemon #%d", a)
+	}
+	slotB, j, _ := s.find(b)
+	if slotB == nil {
+		return fmt.Errorf("you don't have a pokemon #%d", b)
+	}
+
+	(*slotA)[i], (*slotB)[j] = (*slotB)[j], (*slotA)[i]
+	return nil
+}
+
+// parsePokemonID accepts "3" or "#3"
+func parsePokemonID(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("expected a pokemon number like #3, got %s", s)
+	}
+	return id, nil
+}
+
+// describe is a one line summary of an owned pokemon
+func describe(p caughtPokemon) string {
+	if p.Nickname != "" {
+		return fmt.Sprintf("#%d %s (%s)%s Lv %d", p.ID, p.Nickname, p.Species, p.markers(), p.Level)
+	}
+	return fmt.Sprintf("#%d %s%s Lv %d", p.ID, p.Species, p.markers(), p.Level)
+}
+
+func commandParty(ctx context.Context, s *session, args ...string) error {
+	if len(s.storage.Party) == 0 {
+		fmt.Fprintln(s.out, "Your party is empty, go catch some pokemon!")
+		return nil
+	}
+
+	fmt.Fprintln(s.out, "Party:")
+	for i, p := range s.storage.Party {
+		fmt.Fprintf(s.out, " %d. %s\n", i+1, describe(p))
+	}
+	return nil
+}
+
+func commandBox(ctx context.Context, s *session, args ...string) error {
+	box := 1
+	if len(args) > 0 {
+		var err error
+		if box, err = strconv.Atoi(args[0]); err != nil || box < 1 {
+			return fmt.Errorf("expected a box number, got %s", args[0])
+		}
+	}
+	if box > len(s.storage.Boxes) || len(s.storage.Boxes[box-1]) == 0 {
+		fmt.Fprintf(s.out, "Box %d is empty.\n", box)
+		return nil
+	}
+
+	fmt.Fprintf(s.out, "Box %d (%d/%d):\n", box, len(s.storage.Boxes[box-1]), boxSize)
+	for _, p := range s.storage.Boxes[box-1] {
+		fmt.Fprintf(s.out, " - %s\n", describe(p))
+	}
+	return nil
+}
+
+func commandDeposit(ctx context.Context, s *session, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: deposit <#id> [box]")
+	}
+	id, err := parsePokemonID(args[0])
+	if err != nil {
+		return err
+	}
+	box := 0
+	if len(args) > 1 {
+		if box, err = strconv.Atoi(args[1]); err != nil {
+			return fmt.Errorf("expected a box number, got %s", args[1])
+		}
+	}
+
+	if err := s.storage.deposit(id, box); err != nil {
+		return err
+	}
+	_, _, box = s.storage.find(id)
+	fmt.Fprintf(s.out, "#%d was moved to box %d.\n", id, box)
+	return s.save()
+}
+
+func commandWithdraw(ctx context.Context, s *session, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: withdraw <#id>")
+	}
+	id, err := parsePokemonID(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.withdraw(id); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "#%d joined your party.\n", id)
+	return s.save()
+}
+
+func commandRelease(ctx context.Context, s *session, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: release <#id>")
+	}
+	id, err := parsePokemonID(args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := s.storage.release(id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%s was released. Bye!\n", p.displayName())
+	return s.save()
+}
+
+func commandSwap(ctx context.Context, s *session, args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: swap <#id> <#id>")
+	}
+	a, err := parsePokemonID(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := parsePokemonID(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.swap(a, b); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "#%d and #%d swapped places.\n", a, b)
+	return s.save()
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func partyIDs(s pcStorage) []int {
+	var ids []int
+	for _, p := range s.Party {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestStorage(t *testing.T) {
+	var s pcStorage
+	for i := 0; i < partySize+1; i++ {
+		s.add(caughtPokemon{Species: "pikachu"})
+	}
+
+	//the seventh pokemon goes to the PC
+	if len(s.Party) != partySize || len(s.Boxes) != 1 || s.Boxes[0][0].ID != 7 {
+		t.Fatalf("expected a full party and #7 in box 1, got %+v", s)
+	}
+
+	if err := s.withdraw(7); err == nil {
+		t.Errorf("expected withdrawing into a full party to fail")
+	}
+
+	if err := s.swap(2, 7); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	if s.Party[1].ID != 7 || s.Boxes[0][0].ID != 2 {
+		t.Errorf("expected #7 in the party and #2 in box 1, got party %v", partyIDs(s))
+	}
+
+	if err := s.deposit(1, 0); err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+	if _, _, box := s.find(1); box != 1 {
+		t.Errorf("expected #1 in box 1, got %d", box)
+	}
+
+	if err := s.withdraw(2); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	if _, _, box := s.find(2); box != 0 {
+		t.Errorf("expected #2 in the party, got box %d", box)
+	}
+
+	released, err := s.release(3)
+	if err != nil || released.ID != 3 {
+		t.Fatalf("release: %v", err)
+	}
+	if _, ok := s.get(3); ok {
+		t.Errorf("expected #3 to be gone")
+	}
+
+	//numbers are never reused
+	if p, _ := s.add(caughtPokemon{Species: "eevee"}); p.ID != 8 {
+		t.Errorf("expected the next pokemon to be #8, got #%d", p.ID)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/placki-w/pokedexcli/internal/pokeapi"
+)
+
+// defaultMirrorDir keeps the offline dataset with the rest of the user data
+func defaultMirrorDir() string {
+	return filepath.Join(filepath.Dir(defaultSavePath()), "api")
+}
+
+func commandSync(ctx context.Context, s *session, args ...string) error {
+	if s.client.Offline() {
+		return fmt.Errorf("sync needs the network, restart without --offline")
+	}
+
+	endpoints, flags := parseArgs(args)
+	if len(endpoints) == 0 {
+		endpoints = pokeapi.DefaultEndpoints
+	}
+	limit := 0
+	if flags["limit"] != "" {
+		var err error
+		if limit, err = strconv.Atoi(flags["limit"]); err != nil || limit < 1 {
+			return fmt.Errorf("expected a number of resources per endpoint, got %s", flags["limit"])
+		}
+	}
+
+	fmt.Fprintf(s.out, "Syncing %s into %s\n", s.client.BaseURL(), s.client.Mirror().Dir())
+	for _, endpoint := range endpoints {
+		endpoint = normalizeName(endpoint)
+		err := s.client.Sync(ctx, endpoint, limit, func(done, total int) {
+			//rewrite the same line so long syncs don't flood the terminal
+			fmt.Fprintf(s.out, "\r%s: %d/%d", endpoint, done, total)
+		})
+		fmt.Fprintln(s.out)
+		if err != nil {
+			return fmt.Errorf("syncing %s: %w", endpoint, err)
+		}
+	}
+	fmt.Fprintln(s.out, "Done. Start with --offline to use this data without a network.")
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import "math/rand"
+
+// defaultShinyOdds matches the games from generation VI onwards
+const defaultShinyOdds = 4096
+
+const (
+	genderMale   = "male"
+	genderFemale = "female"
+	genderless   = "genderless"
+)
+
+// rollShiny is true one time in odds, never when odds is 0 or less
+func rollShiny(r *rand.Rand, odds int) bool {
+	return odds > 0 && r.Intn(odds) == 0
+}
+
+// rollGender uses the species gender rate: the chance of being female in
+// eighths, or -1 for genderless species
+func rollGender(r *rand.Rand, genderRate int) string {
+	if genderRate < 0 {
+		return genderless
+	}
+	if r.Intn(8) < genderRate {
+		return genderFemale
+	}
+	return genderMale
+}
+
+// markers are the symbols shown next to a pokemon's name
+func (c caughtPokemon) markers() string {
+	markers := ""
+	switch c.Gender {
+	case genderMale:
+		markers += " ♂"
+	case genderFemale:
+		markers += " ♀"
+	}
+	if c.Shiny {
+		markers += " ★"
+	}
+	return markers
+}
+
+// spriteURL picks the sprite matching a pokemon's variant, falling back to
+// the plain sprite where the API has no female or shiny version
+func spriteURL(p pokemonDetails, shiny, female, back bool) string {
+	s := p.Sprites
+	var candidates []string
+	switch {
+	case back && shiny && female:
+		candidates = []string{s.BackShinyFemale, s.BackShiny}
+	case back && shiny:
+		candidates = []string{s.BackShiny}
+	case back && female:
+		candidates = []string{s.BackFemale}
+	case back:
+	case shiny && female:
+		candidates = []string{s.FrontShinyFemale, s.FrontShiny}
+	case shiny:
+		candidates = []string{s.FrontShiny}
+	case female:
+		candidates = []string{s.FrontFemale}
+	}
+	if back {
+		candidates = append(candidates, s.BackDefault)
+	}
+	candidates = append(candidates, s.FrontDefault)
+
+	for _, url := range candidates {
+		if url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// sprite returns the sprite URL for an owned pokemon
+func (c caughtPokemon) sprite(p pokemonDetails) string {
+	return spriteURL(p, c.Shiny, c.Gender == genderFemale, false)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRollVariants(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if rollGender(r, -1) != genderless || rollGender(r, 0) != genderMale || rollGender(r, 8) != genderFemale {
+			t.Fatalf("gender rates -1, 0 and 8 should always roll genderless, male and female")
+		}
+		if !rollShiny(r, 1) || rollShiny(r, 0) {
+			t.Fatalf("shiny odds of 1 should always be shiny and 0 never")
+		}
+	}
+}
+
+func TestSpriteURL(t *testing.T) {
+	p := testPokemon(t, `{"sprites": {
+		"front_default": "front.png",
+		"front_shiny": "front_shiny.png",
+		"front_female": "front_female.png",
+		"back_default": "back.png"
+	}}`)
+
+	cases := []struct {
+		shiny    bool
+		female   bool
+		back     bool
+		expected string
+	}{
+		{expected: "front.png"},
+		{shiny: true, expected: "front_shiny.png"},
+		{female: true, expected: "front_female.png"},
+		{shiny: true, female: true, expected: "front_shiny.png"},
+		{back: true, expected: "back.png"},
+		{back: true, shiny: true, expected: "back.png"},
+	}
+	for _, c := range cases {
+		if actual := spriteURL(p, c.shiny, c.female, c.back); actual != c.expected {
+			t.Errorf("shiny=%v female=%v back=%v: got '%s', expected '%s'", c.shiny, c.female, c.back, actual, c.expected)
+		}
+	}
+}